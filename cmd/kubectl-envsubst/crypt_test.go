@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashmap-kz/kubectl-envsubst/pkg/cmd"
+)
+
+// writeTestKey writes a key file of 32 bytes encoded in base64
+func writeTestKey(t *testing.T, dir string) string {
+	t.Helper()
+	filename := filepath.Join(dir, "prod.key")
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32))
+	if err := os.WriteFile(filename, []byte(key+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestRunCrypt_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	key := writeTestKey(t, dir)
+	plain := "APP_NAME=app\nAPP_PASSWORD=secret\n"
+	envFile := filepath.Join(dir, ".env.prod")
+	if err := os.WriteFile(envFile, []byte(plain), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var encrypted bytes.Buffer
+	flags := &cmd.ArgsRawRecognized{Others: []string{"encrypt", envFile}, EnvsubstDecryptKey: key}
+	if err := runCrypt(flags, &encrypted); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !cmd.IsEncrypted(encrypted.Bytes()) || strings.Contains(encrypted.String(), "secret") {
+		t.Fatalf("Expected encrypted content, got %q", encrypted.String())
+	}
+	if err := os.WriteFile(envFile, encrypted.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var decrypted bytes.Buffer
+	flags = &cmd.ArgsRawRecognized{Others: []string{"decrypt", envFile}, EnvsubstDecryptKey: key}
+	if err := runCrypt(flags, &decrypted); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if decrypted.String() != plain {
+		t.Errorf("Expected %q, got %q", plain, decrypted.String())
+	}
+}
+
+func TestRunCrypt_Stdin(t *testing.T) {
+	dir := t.TempDir()
+	key := writeTestKey(t, dir)
+	plain := "APP_NAME=app\n"
+
+	// Replace os.Stdin with a temporary file
+	stdinFile := filepath.Join(dir, "stdin")
+	if err := os.WriteFile(stdinFile, []byte(plain), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	stdin, err := os.Open(stdinFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdin.Close()
+	originalStdin := os.Stdin
+	defer func() { os.Stdin = originalStdin }()
+	os.Stdin = stdin
+
+	var encrypted bytes.Buffer
+	flags := &cmd.ArgsRawRecognized{Others: []string{"encrypt", "-"}, EnvsubstDecryptKey: key}
+	if err := runCrypt(flags, &encrypted); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	keyContent, err := cmd.LoadKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted, err := cmd.Decrypt(keyContent, encrypted.Bytes())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if string(decrypted) != plain {
+		t.Errorf("Expected %q, got %q", plain, decrypted)
+	}
+}
+
+func TestRunCrypt_Errors(t *testing.T) {
+	dir := t.TempDir()
+	key := writeTestKey(t, dir)
+	keyContent, err := cmd.LoadKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := cmd.Encrypt(keyContent, []byte("APP_NAME=app\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	encryptedFile := filepath.Join(dir, ".env.encrypted")
+	plainFile := filepath.Join(dir, ".env.plain")
+	if err := os.WriteFile(encryptedFile, encrypted, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(plainFile, []byte("APP_NAME=app\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		flags         cmd.ArgsRawRecognized
+		expectedError string
+	}{
+		{
+			name:          "Already encrypted",
+			flags:         cmd.ArgsRawRecognized{Others: []string{"encrypt", encryptedFile}, EnvsubstDecryptKey: key},
+			expectedError: encryptedFile + ": the file is already encrypted",
+		},
+		{
+			name:          "Missing key",
+			flags:         cmd.ArgsRawRecognized{Others: []string{"decrypt", encryptedFile}},
+			expectedError: "decrypt: missing key file, it is set by --envsubst-decrypt-key",
+		},
+		{
+			name:          "Missing file",
+			flags:         cmd.ArgsRawRecognized{Others: []string{"encrypt"}, EnvsubstDecryptKey: key},
+			expectedError: "encrypt: expected exactly one file",
+		},
+		{
+			name:          "Decrypt plain file",
+			flags:         cmd.ArgsRawRecognized{Others: []string{"decrypt", plainFile}, EnvsubstDecryptKey: key},
+			expectedError: plainFile + ": ",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := runCrypt(&test.flags, &out)
+			if err == nil || !strings.HasPrefix(err.Error(), test.expectedError) {
+				t.Errorf("Expected error '%s', got '%v'", test.expectedError, err)
+			}
+			if out.Len() != 0 {
+				t.Errorf("Expected no output, got %q", out.String())
+			}
+		})
+	}
+}
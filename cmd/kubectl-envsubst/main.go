@@ -1,6 +1,8 @@
 package main
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -45,6 +47,11 @@ func runApp() error {
 		return nil
 	}
 
+	// manage encrypted env files
+	if flags.Others[0] == "encrypt" || flags.Others[0] == "decrypt" {
+		return runCrypt(&flags, os.Stdout)
+	}
+
 	// support apply operation only
 	if flags.Others[0] != "apply" {
 		fmt.Println(cmd.UsageMessage)
@@ -63,79 +70,307 @@ func runApp() error {
 		return err
 	}
 
-	// apply STDIN (if any)
-	if flags.HasStdin {
-		err := applyStdin(&flags, kubectl)
+	// load variables from env files, manifests and values files once, they are shared by all the files,
+	// the process environment takes precedence over env files, then overlays of directories of manifests,
+	// then manifests, then values files
+	var key []byte
+	if flags.EnvsubstDecryptKey != "" {
+		key, err = cmd.LoadKey(flags.EnvsubstDecryptKey)
 		if err != nil {
 			return err
 		}
 	}
+	fileVars, err := cmd.LoadEnvFilesWithKey(flags.EnvsubstEnvFiles, key)
+	if err != nil {
+		return err
+	}
+	manifestVars, err := cmd.LoadManifestValues(flags.EnvsubstManifests)
+	if err != nil {
+		return err
+	}
+	values, err := cmd.LoadValuesFiles(flags.EnvsubstValuesFiles)
+	if err != nil {
+		return err
+	}
+	run := &runState{
+		primary: cmd.ChainSource{cmd.EnvSource{}, cmd.NamedSource{Name: "env files", VarSource: cmd.MapSource(fileVars)}},
+		secondary: cmd.ChainSource{
+			cmd.NamedSource{Name: "manifests", VarSource: cmd.MapSource(manifestVars)},
+			cmd.NamedSource{Name: "values files", VarSource: cmd.MapSource(values)},
+		},
+		overlays: cmd.NewOverlayReader(key),
+	}
 
-	// apply passed files
-	for _, filename := range files {
-		err := applyOneFile(&flags, kubectl, filename)
+	// references to external values are enabled explicitly: ${file:certs/tls.crt}, ${exec:version}, ${vault:secret/data/app#password}
+	config := &cmd.Config{}
+	if flags.EnvsubstConfig != "" {
+		config, err = cmd.LoadConfig(flags.EnvsubstConfig)
 		if err != nil {
 			return err
 		}
 	}
+	run.resolvers, err = newResolvers(&flags, config)
+	if err != nil {
+		return err
+	}
 
-	return nil
-}
+	// computed variables are generated once, all the files share the same timestamp, suffix and uuid
+	run.builtins, err = cmd.NewComputedVars(flags.EnvsubstSeed)
+	if err != nil {
+		return err
+	}
+
+	// built-in git variables are read from the repository of each manifest
+	if flags.EnvsubstGit {
+		run.git = cmd.NewGitReader()
+	}
 
-// applyStdin substitutes content, passed to stdin `kubectl apply -f -`
-func applyStdin(flags *cmd.ArgsRawRecognized, kubectl string) error {
-	stdin, err := io.ReadAll(os.Stdin)
+	// read STDIN (if any) and passed files, all the inputs are required by ENVSUBST_HASH
+	inputs, err := readInputs(&flags, files)
 	if err != nil {
 		return err
 	}
+	if err := hashInputs(&flags, run, inputs); err != nil {
+		return err
+	}
 
-	// substitute the whole stream of joined files at once
-	substitutedBuffer, err := substituteContent(flags, stdin)
+	// nothing is applied, unless all the inputs are substituted
+	results, err := substituteInputs(&flags, run, inputs)
 	if err != nil {
 		return err
 	}
+	for _, substitutedBuffer := range results {
+		if err := execKubectl(&flags, kubectl, substitutedBuffer); err != nil {
+			return err
+		}
+	}
 
-	return execKubectl(flags, kubectl, substitutedBuffer)
+	return nil
 }
 
-// applyOneFile read file (url, local-path), substitute its content, apply result
-func applyOneFile(flags *cmd.ArgsRawRecognized, kubectl, filename string) error {
-	// recognize file type
+// runState holds sources of values, that are shared by all the files of a run
+type runState struct {
+	primary   cmd.VarSource
+	secondary cmd.VarSource
+	overlays  *cmd.OverlayReader
+	resolvers map[string]cmd.Resolver
+	builtins  map[string]string
+	git       *cmd.GitReader
+}
 
-	var contentForSubst []byte
-	if cmd.IsURL(filename) {
-		data, err := cmd.ReadRemoteFileContent(filename)
+// input is the content of STDIN or of a file (url, local-path)
+type input struct {
+	filename string
+	content  []byte
+}
+
+// readInputs reads content, passed to stdin `kubectl apply -f -`, and content of all the files
+func readInputs(flags *cmd.ArgsRawRecognized, files []string) ([]input, error) {
+	var inputs []input
+	if flags.HasStdin {
+		stdin, err := io.ReadAll(os.Stdin)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		inputs = append(inputs, input{filename: "<stdin>", content: stdin})
+	}
+
+	for _, filename := range files {
+		// recognize file type
+		var content []byte
+		var err error
+		if cmd.IsURL(filename) {
+			content, err = cmd.ReadRemoteFileContent(filename)
+		} else {
+			content, err = os.ReadFile(filename)
 		}
-		contentForSubst = data
-	} else {
-		data, err := os.ReadFile(filename)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		contentForSubst = data
+		inputs = append(inputs, input{filename: filename, content: content})
 	}
+	return inputs, nil
+}
 
-	// substitute the whole stream of joined files at once
-	substitutedBuffer, err := substituteContent(flags, contentForSubst)
-	if err != nil {
-		return err
+// hashInputs sets ENVSUBST_HASH to the hash of the substituted input set,
+// the hash itself is substituted with an empty string, while it is computed
+func hashInputs(flags *cmd.ArgsRawRecognized, run *runState, inputs []input) error {
+	used := false
+	for _, in := range inputs {
+		if bytes.Contains(in.content, []byte("ENVSUBST_HASH")) {
+			used = true
+			break
+		}
 	}
+	if !used {
+		return nil
+	}
+
+	// placeholders are explained once, while the inputs are substituted for kubectl
+	quiet := *flags
+	quiet.EnvsubstExplain = false
 
-	return execKubectl(flags, kubectl, substitutedBuffer)
+	run.builtins["ENVSUBST_HASH"] = ""
+	results := make([]string, 0, len(inputs))
+	for _, in := range inputs {
+		substitutedBuffer, err := substituteContent(&quiet, run, in.filename, in.content)
+		if err != nil {
+			return err
+		}
+		results = append(results, substitutedBuffer)
+	}
+	run.builtins["ENVSUBST_HASH"] = cmd.HashInputs(results)
+	return nil
+}
+
+// substituteInputs substitutes all the inputs, errors of all the inputs are reported together
+func substituteInputs(flags *cmd.ArgsRawRecognized, run *runState, inputs []input) ([]string, error) {
+	results := make([]string, 0, len(inputs))
+	var errs []error
+	for _, in := range inputs {
+		// substitute the whole stream of joined files at once
+		substitutedBuffer, err := substituteContent(flags, run, in.filename, in.content)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		results = append(results, substitutedBuffer)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return results, nil
 }
 
 // substituteContent runs the subst module for a given content
-func substituteContent(flags *cmd.ArgsRawRecognized, contentForSubst []byte) (string, error) {
-	envSubst := cmd.NewEnvsubst(flags.EnvsubstAllowedVars, flags.EnvsubstAllowedPrefix, true)
+func substituteContent(flags *cmd.ArgsRawRecognized, run *runState, filename string, contentForSubst []byte) (string, error) {
+	// overlays of the directory of a file (.envsubst.env) are placed between shared sources
+	overlayVars, err := run.overlays.Vars(filename)
+	if err != nil {
+		return "", err
+	}
+	source := cmd.ChainSource{run.primary, cmd.NamedSource{Name: cmd.OverlayFilename, VarSource: cmd.MapSource(overlayVars)}, run.secondary}
+
+	envSubst := cmd.NewEnvsubst(flags.EnvsubstAllowedVars, flags.EnvsubstAllowedPrefix, true, source)
+	envSubst.SetFilename(filename)
+	for scheme, resolver := range run.resolvers {
+		envSubst.SetResolver(scheme, resolver)
+	}
+	envSubst.AddBuiltins(run.builtins)
+	if run.git != nil {
+		gitVars, err := run.git.Vars(filename)
+		if err != nil {
+			return "", err
+		}
+		envSubst.AddBuiltins(gitVars)
+	}
+	if err := envSubst.SetAllowedPatterns(flags.EnvsubstAllowedPatterns); err != nil {
+		return "", err
+	}
+	if flags.EnvsubstStrict != "" {
+		if err := envSubst.SetStrict(flags.EnvsubstStrict); err != nil {
+			return "", err
+		}
+	}
+	envSubst.SetDenied(flags.EnvsubstDeniedVars, flags.EnvsubstDeniedPrefixes)
+	if flags.EnvsubstDeniedPolicy != "" {
+		if err := envSubst.SetDeniedPolicy(flags.EnvsubstDeniedPolicy); err != nil {
+			return "", err
+		}
+	}
+	envSubst.SetBracesOnly(flags.EnvsubstBracesOnly)
+	if flags.EnvsubstDelimiters != "" {
+		if err := envSubst.SetDelimiters(flags.EnvsubstDelimiters); err != nil {
+			return "", err
+		}
+	}
+	envSubst.SetExplain(flags.EnvsubstExplain)
+	envSubst.SetExplainValues(flags.EnvsubstExplainValues)
 	substitutedBuffer, err := envSubst.SubstituteEnvs(string(contentForSubst))
+
+	// explain placeholders of a file before its errors, to find out why a variable was not substituted
+	if flags.EnvsubstExplain {
+		_, _ = fmt.Fprintf(os.Stderr, "# %s\n", filename)
+		if explainErr := cmd.WriteExplanations(os.Stderr, envSubst.Explanations()); explainErr != nil {
+			return "", explainErr
+		}
+	}
 	if err != nil {
 		return "", err
 	}
 	return substitutedBuffer, nil
 }
 
+// newResolvers creates resolvers of references, that are enabled by flags and the config file
+func newResolvers(flags *cmd.ArgsRawRecognized, config *cmd.Config) (map[string]cmd.Resolver, error) {
+	resolvers := make(map[string]cmd.Resolver)
+
+	// hashes of files do not expose their content, without a root they are restricted to the working directory
+	root := flags.EnvsubstFileRoot
+	if root == "" {
+		root = "."
+	}
+	fileResolver, err := cmd.NewFileResolver(root)
+	if err != nil {
+		return nil, err
+	}
+	if flags.EnvsubstFileRoot != "" {
+		resolvers["file"] = fileResolver
+	}
+	resolvers["ENVSUBST_HASH"] = cmd.NewHashResolver(fileResolver)
+
+	// secrets are read only when they are referenced, so VAULT_ADDR and VAULT_TOKEN are not required otherwise
+	if flags.EnvsubstVault {
+		resolvers["vault"] = cmd.NewVaultResolverFromEnv()
+	}
+	// commands are refused, unless they are declared in the config file
+	if flags.EnvsubstConfig != "" {
+		resolvers["exec"] = cmd.NewExecResolver(config.Exec)
+	}
+	return resolvers, nil
+}
+
+// runCrypt encrypts or decrypts an env file: `kubectl envsubst encrypt --envsubst-decrypt-key prod.key .env.prod`,
+// the result is written to out, '-' reads the file from stdin
+func runCrypt(flags *cmd.ArgsRawRecognized, out io.Writer) error {
+	operation := flags.Others[0]
+	if len(flags.Others) != 2 {
+		return fmt.Errorf("%s: expected exactly one file", operation)
+	}
+	if flags.EnvsubstDecryptKey == "" {
+		return fmt.Errorf("%s: missing key file, it is set by --envsubst-decrypt-key", operation)
+	}
+	key, err := cmd.LoadKey(flags.EnvsubstDecryptKey)
+	if err != nil {
+		return err
+	}
+
+	var content []byte
+	if flags.Others[1] == "-" {
+		content, err = io.ReadAll(os.Stdin)
+	} else {
+		content, err = os.ReadFile(flags.Others[1])
+	}
+	if err != nil {
+		return err
+	}
+
+	var result []byte
+	if operation == "encrypt" {
+		if cmd.IsEncrypted(content) {
+			return fmt.Errorf("%s: the file is already encrypted", flags.Others[1])
+		}
+		result, err = cmd.Encrypt(key, content)
+	} else {
+		result, err = cmd.Decrypt(key, content)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", flags.Others[1], err)
+	}
+	_, err = out.Write(result)
+	return err
+}
+
 // execKubectl applies a result buffer, bu running `kubectl apply -f -`
 func execKubectl(flags *cmd.ArgsRawRecognized, kubectl, substitutedBuffer string) error {
 	// prepare kubectl args
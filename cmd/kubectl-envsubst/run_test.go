@@ -0,0 +1,224 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/hashmap-kz/kubectl-envsubst/pkg/cmd"
+)
+
+// newTestRun creates a run state, that reads variables from the given map only
+func newTestRun(t *testing.T, vars map[string]string) *runState {
+	t.Helper()
+	builtins, err := cmd.NewComputedVars("42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &runState{
+		primary:   cmd.MapSource(vars),
+		secondary: cmd.MapSource{},
+		overlays:  cmd.NewOverlayReader(nil),
+		builtins:  builtins,
+	}
+}
+
+// captureStderr returns everything, that is written to os.Stderr by fn
+func captureStderr(t *testing.T, fn func()) string {
+	t.Helper()
+	stderrFile, err := os.CreateTemp(t.TempDir(), "mock-stderr-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stderrFile.Close()
+
+	originalStderr := os.Stderr
+	defer func() { os.Stderr = originalStderr }()
+	os.Stderr = stderrFile
+	fn()
+
+	if _, err := stderrFile.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	output, err := io.ReadAll(stderrFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(output)
+}
+
+func TestHashInputs_Stable(t *testing.T) {
+	flags := &cmd.ArgsRawRecognized{EnvsubstAllowedVars: []string{"APP_NAME"}}
+	inputs := []input{
+		{filename: "a.yaml", content: []byte("name: ${APP_NAME}\nhash: ${ENVSUBST_HASH}\n")},
+		{filename: "b.yaml", content: []byte("kind: ConfigMap\n")},
+	}
+	hash := func(vars map[string]string, inputs []input) string {
+		t.Helper()
+		run := newTestRun(t, vars)
+		if err := hashInputs(flags, run, inputs); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return run.builtins["ENVSUBST_HASH"]
+	}
+
+	first := hash(map[string]string{"APP_NAME": "app"}, inputs)
+	if first == "" {
+		t.Fatal("Expected ENVSUBST_HASH to be set")
+	}
+	if second := hash(map[string]string{"APP_NAME": "app"}, inputs); second != first {
+		t.Errorf("Expected a stable hash %s, got %s", first, second)
+	}
+
+	// the hash follows substituted values and content of the inputs
+	if other := hash(map[string]string{"APP_NAME": "other"}, inputs); other == first {
+		t.Errorf("Expected another hash for another value, got %s", other)
+	}
+	changed := []input{inputs[0], {filename: "b.yaml", content: []byte("kind: Secret\n")}}
+	if other := hash(map[string]string{"APP_NAME": "app"}, changed); other == first {
+		t.Errorf("Expected another hash for another input, got %s", other)
+	}
+
+	// the hash is substituted into the inputs, which are applied
+	run := newTestRun(t, map[string]string{"APP_NAME": "app"})
+	if err := hashInputs(flags, run, inputs); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	result, err := substituteContent(flags, run, inputs[0].filename, inputs[0].content)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if expected := "name: app\nhash: " + first + "\n"; result != expected {
+		t.Errorf("Expected %q, got %q", expected, result)
+	}
+}
+
+func TestHashInputs_NotUsed(t *testing.T) {
+	run := newTestRun(t, nil)
+	inputs := []input{{filename: "a.yaml", content: []byte("kind: ConfigMap\n")}}
+	if err := hashInputs(&cmd.ArgsRawRecognized{}, run, inputs); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := run.builtins["ENVSUBST_HASH"]; ok {
+		t.Errorf("Expected ENVSUBST_HASH not to be computed, got %v", run.builtins)
+	}
+}
+
+func TestHashInputs_ExplainOnce(t *testing.T) {
+	flags := &cmd.ArgsRawRecognized{EnvsubstAllowedVars: []string{"APP_NAME"}, EnvsubstExplain: true}
+	run := newTestRun(t, map[string]string{"APP_NAME": "app"})
+	inputs := []input{
+		{filename: "a.yaml", content: []byte("name: ${APP_NAME}\nhash: ${ENVSUBST_HASH}\n")},
+		{filename: "b.yaml", content: []byte("name: ${APP_NAME}\n")},
+	}
+
+	output := captureStderr(t, func() {
+		if err := hashInputs(flags, run, inputs); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		for _, in := range inputs {
+			if _, err := substituteContent(flags, run, in.filename, in.content); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		}
+	})
+
+	for _, in := range inputs {
+		if count := strings.Count(output, "# "+in.filename+"\n"); count != 1 {
+			t.Errorf("Expected %s to be explained once, got %d times:\n%s", in.filename, count, output)
+		}
+	}
+	if !flags.EnvsubstExplain {
+		t.Errorf("Expected flags not to be modified")
+	}
+}
+
+func TestSubstituteInputs(t *testing.T) {
+	flags := &cmd.ArgsRawRecognized{EnvsubstAllowedVars: []string{"APP_NAME", "APP_IMAGE", "APP_PORT"}}
+	run := newTestRun(t, map[string]string{"APP_NAME": "app"})
+
+	results, err := substituteInputs(flags, run, []input{
+		{filename: "a.yaml", content: []byte("name: ${APP_NAME}\n")},
+		{filename: "b.yaml", content: []byte("kind: ConfigMap\n")},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if expected := []string{"name: app\n", "kind: ConfigMap\n"}; !reflect.DeepEqual(results, expected) {
+		t.Errorf("Expected %q, got %q", expected, results)
+	}
+
+	// nothing is returned for kubectl, when one of the inputs fails, errors of all the inputs are reported
+	results, err = substituteInputs(flags, run, []input{
+		{filename: "a.yaml", content: []byte("image: ${APP_IMAGE}\n")},
+		{filename: "b.yaml", content: []byte("name: ${APP_NAME}\n")},
+		{filename: "c.yaml", content: []byte("port: ${APP_PORT}\n")},
+	})
+	if results != nil {
+		t.Errorf("Expected no results, got %q", results)
+	}
+	expectedError := "undefined variables: [APP_IMAGE]\n  a.yaml:1:8: ${APP_IMAGE}\n" +
+		"undefined variables: [APP_PORT]\n  c.yaml:1:7: ${APP_PORT}"
+	if err == nil || err.Error() != expectedError {
+		t.Errorf("Expected error '%s', got '%v'", expectedError, err)
+	}
+}
+
+func TestNewResolvers(t *testing.T) {
+	root := t.TempDir()
+	config := &cmd.Config{Exec: map[string]cmd.ExecCommand{"version": {Command: []string{"echo", "v1"}}}}
+
+	tests := []struct {
+		name     string
+		flags    cmd.ArgsRawRecognized
+		expected []string
+	}{
+		{
+			name:     "Defaults",
+			expected: []string{"ENVSUBST_HASH"},
+		},
+		{
+			name:     "File root",
+			flags:    cmd.ArgsRawRecognized{EnvsubstFileRoot: root},
+			expected: []string{"ENVSUBST_HASH", "file"},
+		},
+		{
+			name:     "Config",
+			flags:    cmd.ArgsRawRecognized{EnvsubstConfig: filepath.Join(root, "config.yaml")},
+			expected: []string{"ENVSUBST_HASH", "exec"},
+		},
+		{
+			name:     "Vault",
+			flags:    cmd.ArgsRawRecognized{EnvsubstVault: true},
+			expected: []string{"ENVSUBST_HASH", "vault"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resolvers, err := newResolvers(&test.flags, config)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			schemes := make([]string, 0, len(resolvers))
+			for scheme := range resolvers {
+				schemes = append(schemes, scheme)
+			}
+			sort.Strings(schemes)
+			if !reflect.DeepEqual(schemes, test.expected) {
+				t.Errorf("Expected resolvers %v, got %v", test.expected, schemes)
+			}
+		})
+	}
+}
+
+func TestNewResolvers_InvalidFileRoot(t *testing.T) {
+	flags := &cmd.ArgsRawRecognized{EnvsubstFileRoot: filepath.Join(t.TempDir(), "missing")}
+	if _, err := newResolvers(flags, &cmd.Config{}); err == nil {
+		t.Errorf("Expected an error for a missing file root")
+	}
+}
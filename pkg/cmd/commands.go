@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultExecTimeout limits the execution of a command of a reference, when the config sets no timeout
+const defaultExecTimeout = 10 * time.Second
+
+// ExecResolver resolves references to outputs of allowed commands: ${exec:version}.
+// Each command is executed once, its output (or error) is shared by all the references.
+type ExecResolver struct {
+	commands map[string]ExecCommand
+
+	mu      sync.Mutex
+	outputs map[string]execOutput
+}
+
+type execOutput struct {
+	value string
+	err   error
+}
+
+// NewExecResolver creates a resolver of commands of the allowlist
+func NewExecResolver(commands map[string]ExecCommand) *ExecResolver {
+	return &ExecResolver{
+		commands: commands,
+		outputs:  make(map[string]execOutput),
+	}
+}
+
+func (r *ExecResolver) Resolve(ref, _ string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if output, ok := r.outputs[ref]; ok {
+		return output.value, output.err
+	}
+	command, ok := r.commands[ref]
+	if !ok {
+		return "", fmt.Errorf("command %s is not allowed, it is not declared in the exec section of the config file", ref)
+	}
+	value, err := runCommand(ref, command)
+	r.outputs[ref] = execOutput{value: value, err: err}
+	return value, err
+}
+
+// runCommand executes a command, it returns its output without trailing line breaks
+func runCommand(name string, command ExecCommand) (string, error) {
+	timeout := command.Timeout
+	if timeout == 0 {
+		timeout = defaultExecTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, command.Command[0], command.Command[1:]...)
+	var stdoutBuf, stderrBuf bytes.Buffer
+	cmd.Stdout = &stdoutBuf
+	cmd.Stderr = &stderrBuf
+
+	err := cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return "", fmt.Errorf("command %s timed out after %s", name, timeout)
+	}
+	if err != nil {
+		return "", fmt.Errorf("command %s failed: %s", name, strings.TrimSpace(getErrorDesc(err, stderrBuf)))
+	}
+	return strings.TrimRight(stdoutBuf.String(), "\r\n"), nil
+}
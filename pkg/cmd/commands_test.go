@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecResolver(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("commands use a POSIX shell")
+	}
+	counter := filepath.Join(t.TempDir(), "counter")
+
+	resolver := NewExecResolver(map[string]ExecCommand{
+		"version": {Command: []string{"sh", "-c", "echo run >> " + counter + "; printf 'v1.2.3\\n\\n'"}},
+		"failing": {Command: []string{"sh", "-c", "echo broken >&2; exit 3"}},
+		"slow":    {Command: []string{"sleep", "5"}, Timeout: 50 * time.Millisecond},
+		"missing": {Command: []string{"envsubst-missing-command"}},
+	})
+
+	t.Run("Output is cached", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			value, err := resolver.Resolve("version", "deployment.yaml")
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if value != "v1.2.3" {
+				t.Errorf("Expected 'v1.2.3', got '%s'", value)
+			}
+		}
+		runs, err := os.ReadFile(counter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count := strings.Count(string(runs), "run"); count != 1 {
+			t.Errorf("Expected the command to run once, it ran %d times", count)
+		}
+	})
+
+	tests := []struct {
+		name          string
+		ref           string
+		expectedError string
+	}{
+		{name: "Not allowed", ref: "whoami", expectedError: "command whoami is not allowed, it is not declared in the exec section of the config file"},
+		{name: "Failing command", ref: "failing", expectedError: "command failing failed: broken"},
+		{name: "Timeout", ref: "slow", expectedError: "command slow timed out after 50ms"},
+		{name: "Missing executable", ref: "missing", expectedError: "command missing failed:"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := resolver.Resolve(test.ref, "deployment.yaml")
+			if err == nil || !strings.HasPrefix(err.Error(), test.expectedError) {
+				t.Errorf("Expected error '%s', got '%v'", test.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestSubstituteEnvs_ExecReferences(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("commands use a POSIX shell")
+	}
+	resolver := NewExecResolver(map[string]ExecCommand{
+		"version": {Command: []string{"echo", "v1.2.3"}},
+	})
+
+	envsubst := NewEnvsubst([]string{}, []string{"APP_", "exec:"}, true, MapSource{})
+	envsubst.SetFilename("deployment.yaml")
+	envsubst.SetResolver("exec", resolver)
+
+	result, err := envsubst.SubstituteEnvs("image: app:${exec:version}\nlabel: ${exec:version | dns1123}")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := "image: app:v1.2.3\nlabel: v1-2-3"
+	if result != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, result)
+	}
+
+	_, err = envsubst.SubstituteEnvs("\nname: ${exec:whoami}")
+	expectedError := "deployment.yaml:2:7: ${exec:whoami}: command whoami is not allowed, it is not declared in the exec section of the config file"
+	if err == nil || err.Error() != expectedError {
+		t.Errorf("Expected error '%s', got '%v'", expectedError, err)
+	}
+}
@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	mathrand "math/rand/v2"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Computed built-in variables are generated once per run, and are shared by all the files:
+//
+//	ENVSUBST_TIMESTAMP      the time of the run in RFC 3339 format, UTC
+//	ENVSUBST_RANDOM_SUFFIX  5 random characters, valid in names of resources: x7k2q
+//	ENVSUBST_UUID           a random UUID (version 4)
+//	ENVSUBST_HASH           sha256 of the substituted input set, ${ENVSUBST_HASH:path} is sha256 of a file
+//
+// With a seed, random values are reproducible, and the timestamp is the Unix epoch. SOURCE_DATE_EPOCH
+// sets the timestamp in both cases.
+
+// suffixAlphabet holds characters of random suffixes, the same ones as in generated names of resources
+const suffixAlphabet = "bcdfghjklmnpqrstvwxz2456789"
+
+// suffixLength is the number of characters of a random suffix
+const suffixLength = 5
+
+// sourceDateEpochEnv sets the timestamp of reproducible builds
+const sourceDateEpochEnv = "SOURCE_DATE_EPOCH"
+
+// NewComputedVars generates computed built-in variables, the seed is optional
+func NewComputedVars(seed string) (map[string]string, error) {
+	now := time.Now()
+	var random io.Reader = rand.Reader
+	if seed != "" {
+		value, err := strconv.ParseInt(seed, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid seed %q, expected an integer", seed)
+		}
+		random = mathrand.NewChaCha8(sha256.Sum256([]byte(strconv.FormatInt(value, 10))))
+		now = time.Unix(0, 0)
+	}
+	if epoch, ok := os.LookupEnv(sourceDateEpochEnv); ok {
+		seconds, err := strconv.ParseInt(epoch, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for env: %s", sourceDateEpochEnv)
+		}
+		now = time.Unix(seconds, 0)
+	}
+
+	suffix, err := randomSuffix(random)
+	if err != nil {
+		return nil, err
+	}
+	uuid, err := randomUUID(random)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{
+		"ENVSUBST_TIMESTAMP":     now.UTC().Format(time.RFC3339),
+		"ENVSUBST_RANDOM_SUFFIX": suffix,
+		"ENVSUBST_UUID":          uuid,
+	}, nil
+}
+
+// randomSuffix returns random characters of the suffix alphabet, bytes that would bias the choice are skipped
+func randomSuffix(random io.Reader) (string, error) {
+	limit := 256 - 256%len(suffixAlphabet)
+	suffix := make([]byte, 0, suffixLength)
+	buf := make([]byte, 1)
+	for len(suffix) < suffixLength {
+		if _, err := io.ReadFull(random, buf); err != nil {
+			return "", err
+		}
+		if int(buf[0]) < limit {
+			suffix = append(suffix, suffixAlphabet[int(buf[0])%len(suffixAlphabet)])
+		}
+	}
+	return string(suffix), nil
+}
+
+// randomUUID returns a random UUID, version 4
+func randomUUID(random io.Reader) (string, error) {
+	var b [16]byte
+	if _, err := io.ReadFull(random, b[:]); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	h := hex.EncodeToString(b[:])
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:], nil
+}
+
+// HashInputs returns sha256 of a set of substituted inputs, the order of inputs matters
+func HashInputs(inputs []string) string {
+	h := sha256.New()
+	for _, input := range inputs {
+		fmt.Fprintf(h, "%d:%s", len(input), input)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// HashResolver resolves references to hashes of files: ${ENVSUBST_HASH:configmap.yaml},
+// files are read the same way as file references
+type HashResolver struct {
+	files *FileResolver
+}
+
+func NewHashResolver(files *FileResolver) *HashResolver {
+	return &HashResolver{files: files}
+}
+
+func (r *HashResolver) Resolve(ref, filename string) (string, error) {
+	content, err := r.files.Resolve(ref, filename)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestNewComputedVars(t *testing.T) {
+	os.Unsetenv("SOURCE_DATE_EPOCH")
+
+	vars, err := NewComputedVars("")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	suffix := vars["ENVSUBST_RANDOM_SUFFIX"]
+	if len(suffix) != suffixLength || strings.Trim(suffix, suffixAlphabet) != "" {
+		t.Errorf("Expected a suffix of %d characters of '%s', got '%s'", suffixLength, suffixAlphabet, suffix)
+	}
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	if !uuid.MatchString(vars["ENVSUBST_UUID"]) {
+		t.Errorf("Expected a UUID, got '%s'", vars["ENVSUBST_UUID"])
+	}
+	if !regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}Z$`).MatchString(vars["ENVSUBST_TIMESTAMP"]) {
+		t.Errorf("Expected an RFC 3339 timestamp, got '%s'", vars["ENVSUBST_TIMESTAMP"])
+	}
+}
+
+func TestNewComputedVars_Seed(t *testing.T) {
+	os.Unsetenv("SOURCE_DATE_EPOCH")
+
+	first, err := NewComputedVars("42")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	second, err := NewComputedVars("42")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	other, err := NewComputedVars("43")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for name, value := range first {
+		if second[name] != value {
+			t.Errorf("Expected %s to be reproducible, got '%s' and '%s'", name, value, second[name])
+		}
+	}
+	if first["ENVSUBST_TIMESTAMP"] != "1970-01-01T00:00:00Z" {
+		t.Errorf("Expected the Unix epoch, got '%s'", first["ENVSUBST_TIMESTAMP"])
+	}
+	if first["ENVSUBST_UUID"] == other["ENVSUBST_UUID"] {
+		t.Errorf("Expected different seeds to produce different values, got '%s'", other["ENVSUBST_UUID"])
+	}
+}
+
+func TestNewComputedVars_SourceDateEpoch(t *testing.T) {
+	os.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	defer os.Unsetenv("SOURCE_DATE_EPOCH")
+
+	vars, err := NewComputedVars("")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if vars["ENVSUBST_TIMESTAMP"] != "2023-11-14T22:13:20Z" {
+		t.Errorf("Expected '2023-11-14T22:13:20Z', got '%s'", vars["ENVSUBST_TIMESTAMP"])
+	}
+
+	os.Setenv("SOURCE_DATE_EPOCH", "yesterday")
+	if _, err := NewComputedVars(""); err == nil || err.Error() != "invalid value for env: SOURCE_DATE_EPOCH" {
+		t.Errorf("Expected an error of SOURCE_DATE_EPOCH, got '%v'", err)
+	}
+}
+
+func TestNewComputedVars_InvalidSeed(t *testing.T) {
+	_, err := NewComputedVars("abc")
+	if err == nil || err.Error() != `invalid seed "abc", expected an integer` {
+		t.Errorf("Expected an invalid seed error, got '%v'", err)
+	}
+}
+
+func TestHashInputs(t *testing.T) {
+	tests := []struct {
+		name  string
+		a     []string
+		b     []string
+		equal bool
+	}{
+		{name: "Same inputs", a: []string{"a: 1", "b: 2"}, b: []string{"a: 1", "b: 2"}, equal: true},
+		{name: "Order matters", a: []string{"a: 1", "b: 2"}, b: []string{"b: 2", "a: 1"}, equal: false},
+		{name: "Boundaries matter", a: []string{"ab", "c"}, b: []string{"a", "bc"}, equal: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if (HashInputs(test.a) == HashInputs(test.b)) != test.equal {
+				t.Errorf("Expected equal hashes: %v, got '%s' and '%s'", test.equal, HashInputs(test.a), HashInputs(test.b))
+			}
+		})
+	}
+}
+
+func TestHashResolver(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "configmap.yaml"), "kind: ConfigMap\n")
+	files, err := NewFileResolver(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolver := NewHashResolver(files)
+
+	sum := sha256.Sum256([]byte("kind: ConfigMap\n"))
+	result, err := resolver.Resolve("configmap.yaml", filepath.Join(dir, "deployment.yaml"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result != hex.EncodeToString(sum[:]) {
+		t.Errorf("Expected '%s', got '%s'", hex.EncodeToString(sum[:]), result)
+	}
+
+	_, err = resolver.Resolve("../secret.yaml", filepath.Join(dir, "deployment.yaml"))
+	if err == nil || !strings.Contains(err.Error(), "is outside of the root directory") {
+		t.Errorf("Expected an error of the root directory, got '%v'", err)
+	}
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds settings of the plugin config file, set with --envsubst-config:
+//
+//	exec:
+//	  version:                                  # ${exec:version}
+//	    command: [git, describe, --tags, --always]
+//	    timeout: 5s                             # 10s by default
+type Config struct {
+	// Exec holds the allowlist of commands of references: ${exec:version}
+	Exec map[string]ExecCommand `yaml:"exec"`
+}
+
+// ExecCommand is a command, that is allowed in references: ${exec:name}
+type ExecCommand struct {
+	// Command holds the executable and its arguments, a shell is not involved
+	Command []string      `yaml:"command"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// LoadConfig reads the plugin config file, unknown fields are errors
+func LoadConfig(filename string) (*Config, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	config, err := parseConfig(content)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return config, nil
+}
+
+func parseConfig(content []byte) (*Config, error) {
+	config := &Config{}
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	for name, command := range config.Exec {
+		if !isName(name) {
+			return nil, fmt.Errorf("exec: invalid command name %q", name)
+		}
+		if len(command.Command) == 0 || command.Command[0] == "" {
+			return nil, fmt.Errorf("exec: %s: missing command", name)
+		}
+		if command.Timeout < 0 {
+			return nil, fmt.Errorf("exec: %s: invalid timeout %s", name, command.Timeout)
+		}
+	}
+	return config, nil
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseConfig(t *testing.T) {
+	content := `
+exec:
+  version:
+    command: [git, describe, --tags, --always]
+    timeout: 5s
+  build_id:
+    command: ["date", "+%s"]
+`
+	config, err := parseConfig([]byte(content))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := map[string]ExecCommand{
+		"version":  {Command: []string{"git", "describe", "--tags", "--always"}, Timeout: 5 * time.Second},
+		"build_id": {Command: []string{"date", "+%s"}},
+	}
+	if !reflect.DeepEqual(config.Exec, expected) {
+		t.Errorf("Expected %v, got %v", expected, config.Exec)
+	}
+}
+
+func TestParseConfig_Empty(t *testing.T) {
+	config, err := parseConfig([]byte("# no settings\n"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(config.Exec) != 0 {
+		t.Errorf("Expected no commands, got %v", config.Exec)
+	}
+}
+
+func TestParseConfig_Errors(t *testing.T) {
+	tests := []struct {
+		name          string
+		content       string
+		expectedError string
+	}{
+		{name: "Unknown field", content: "exec:\n  version:\n    cmd: [git]\n", expectedError: "field cmd not found"},
+		{name: "Missing command", content: "exec:\n  version:\n    timeout: 1s\n", expectedError: "exec: version: missing command"},
+		{name: "Invalid name", content: "exec:\n  git-version:\n    command: [git]\n", expectedError: `exec: invalid command name "git-version"`},
+		{name: "Invalid timeout", content: "exec:\n  version:\n    command: [git]\n    timeout: soon\n", expectedError: "cannot unmarshal"},
+		{name: "Negative timeout", content: "exec:\n  version:\n    command: [git]\n    timeout: -1s\n", expectedError: "exec: version: invalid timeout -1s"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := parseConfig([]byte(test.content))
+			if err == nil || !strings.Contains(err.Error(), test.expectedError) {
+				t.Errorf("Expected error containing '%s', got '%v'", test.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "envsubst.yaml")
+	writeTestFile(t, filename, "exec:\n  version:\n    command: [echo, v1]\n")
+
+	config, err := LoadConfig(filename)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := config.Exec["version"]; !ok {
+		t.Errorf("Expected the version command, got %v", config.Exec)
+	}
+
+	invalid := filepath.Join(dir, "invalid.yaml")
+	writeTestFile(t, invalid, "unknown: true\n")
+	if _, err := LoadConfig(invalid); err == nil || !strings.HasPrefix(err.Error(), invalid+": ") {
+		t.Errorf("Expected an error naming the file, got %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Encrypted env files hold a header line, followed by the nonce and the ciphertext of a dotenv file,
+// encoded in base64 and wrapped at 64 characters:
+//
+//	ENVSUBST-ENCRYPTED v1 aes-256-gcm
+//	3q2+7wAAAAAAAAAA...
+//
+// The key file holds 32 bytes encoded in base64: openssl rand -base64 32 > prod.key
+
+// encryptedHeader starts encrypted files, it is also authenticated as additional data
+const encryptedHeader = "ENVSUBST-ENCRYPTED v1 aes-256-gcm"
+
+// encryptedLineLength is the length of base64 lines of encrypted files
+const encryptedLineLength = 64
+
+// LoadKey reads a key file: 32 bytes encoded in base64
+func LoadKey(filename string) ([]byte, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(content)))
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("invalid key file %s, expected 32 bytes encoded in base64", filename)
+	}
+	return key, nil
+}
+
+// IsEncrypted reports whether the content is an encrypted env file
+func IsEncrypted(content []byte) bool {
+	return bytes.HasPrefix(content, []byte(encryptedHeader+"\n"))
+}
+
+// Encrypt encrypts the content of a dotenv file with AES-256-GCM
+func Encrypt(key, content []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	sealed := aead.Seal(nonce, nonce, content, []byte(encryptedHeader))
+
+	encoded := base64.StdEncoding.EncodeToString(sealed)
+	var out strings.Builder
+	out.WriteString(encryptedHeader + "\n")
+	for len(encoded) > encryptedLineLength {
+		out.WriteString(encoded[:encryptedLineLength] + "\n")
+		encoded = encoded[encryptedLineLength:]
+	}
+	out.WriteString(encoded + "\n")
+	return []byte(out.String()), nil
+}
+
+// Decrypt decrypts an encrypted env file, the result is kept in memory only
+func Decrypt(key, content []byte) ([]byte, error) {
+	if !IsEncrypted(content) {
+		return nil, fmt.Errorf("not an encrypted file, expected the header: %s", encryptedHeader)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	payload := strings.Join(strings.Fields(string(content[len(encryptedHeader)+1:])), "")
+	sealed, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("malformed encrypted file")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ciphertext, []byte(encryptedHeader))
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt, the key is wrong or the file is corrupted")
+	}
+	return plain, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
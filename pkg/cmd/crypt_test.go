@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testKey = bytes.Repeat([]byte{0x2a}, 32)
+
+func TestEncryptDecrypt(t *testing.T) {
+	content := []byte("APP_PASSWORD=" + strings.Repeat("secret", 20) + "\n")
+	encrypted, err := Encrypt(testKey, content)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !IsEncrypted(encrypted) {
+		t.Fatalf("Expected the header, got '%s'", encrypted)
+	}
+	if bytes.Contains(encrypted, []byte("secret")) {
+		t.Errorf("Expected no plain text in '%s'", encrypted)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(encrypted)), "\n")[1:] {
+		if len(line) > encryptedLineLength {
+			t.Errorf("Expected lines of at most %d characters, got '%s'", encryptedLineLength, line)
+		}
+	}
+
+	decrypted, err := Decrypt(testKey, encrypted)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !bytes.Equal(decrypted, content) {
+		t.Errorf("Expected '%s', got '%s'", content, decrypted)
+	}
+
+	// a nonce is random, the same content is encrypted differently
+	again, err := Encrypt(testKey, content)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if bytes.Equal(again, encrypted) {
+		t.Error("Expected different ciphertexts of the same content")
+	}
+}
+
+func TestDecrypt_Errors(t *testing.T) {
+	encrypted, err := Encrypt(testKey, []byte("APP_NAME=my-app\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// flip a bit of the ciphertext
+	sealed, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(string(encrypted[len(encryptedHeader)+1:])), ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed[len(sealed)-1] ^= 0x01
+	tampered := []byte(encryptedHeader + "\n" + base64.StdEncoding.EncodeToString(sealed) + "\n")
+
+	tests := []struct {
+		name          string
+		key           []byte
+		content       []byte
+		expectedError string
+	}{
+		{name: "Wrong key", key: bytes.Repeat([]byte{0x01}, 32), content: encrypted, expectedError: "cannot decrypt, the key is wrong or the file is corrupted"},
+		{name: "Tampered content", key: testKey, content: tampered, expectedError: "cannot decrypt, the key is wrong or the file is corrupted"},
+		{name: "Plain file", key: testKey, content: []byte("APP_NAME=my-app\n"), expectedError: "not an encrypted file, expected the header: " + encryptedHeader},
+		{name: "Malformed payload", key: testKey, content: []byte(encryptedHeader + "\n%%%\n"), expectedError: "malformed encrypted file"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Decrypt(test.key, test.content)
+			if err == nil || err.Error() != test.expectedError {
+				t.Errorf("Expected error '%s', got '%v'", test.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestLoadKey(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.key")
+	short := filepath.Join(dir, "short.key")
+	writeTestFile(t, valid, base64.StdEncoding.EncodeToString(testKey)+"\n")
+	writeTestFile(t, short, base64.StdEncoding.EncodeToString([]byte("short"))+"\n")
+
+	key, err := LoadKey(valid)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !bytes.Equal(key, testKey) {
+		t.Errorf("Expected %v, got %v", testKey, key)
+	}
+
+	_, err = LoadKey(short)
+	if err == nil || !strings.Contains(err.Error(), "expected 32 bytes encoded in base64") {
+		t.Errorf("Expected an error of the key size, got '%v'", err)
+	}
+}
+
+func TestLoadEnvFilesWithKey(t *testing.T) {
+	dir := t.TempDir()
+	common := filepath.Join(dir, "common.env")
+	prod := filepath.Join(dir, "prod.env.enc")
+	writeTestFile(t, common, "APP_ENV=dev\nAPP_USER=app\n")
+	encrypted, err := Encrypt(testKey, []byte("APP_ENV=prod\nAPP_PASSWORD=${APP_USER}-secret\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(prod, encrypted, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// encrypted and plain files are combined, and parsed the same way
+	vars, err := LoadEnvFilesWithKey([]string{common, prod}, testKey)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := map[string]string{
+		"APP_ENV":      "prod",
+		"APP_USER":     "app",
+		"APP_PASSWORD": "app-secret",
+	}
+	if !reflect.DeepEqual(vars, expected) {
+		t.Errorf("Expected %v, got %v", expected, vars)
+	}
+
+	_, err = LoadEnvFiles([]string{prod})
+	if err == nil || err.Error() != prod+": the file is encrypted, a decryption key is required" {
+		t.Errorf("Expected an error of the missing key, got '%v'", err)
+	}
+	_, err = LoadEnvFilesWithKey([]string{prod}, bytes.Repeat([]byte{0x01}, 32))
+	if err == nil || err.Error() != prod+": cannot decrypt, the key is wrong or the file is corrupted" {
+		t.Errorf("Expected an error of the wrong key, got '%v'", err)
+	}
+}
@@ -0,0 +1,303 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Dotenv files hold variables, one per line:
+//
+//	# comment
+//	export APP_NAME=my-app          # the export prefix is optional, inline comments follow whitespace
+//	APP_GREETING="Hello,\n${USER}"  # escape sequences and references are expanded in double quotes
+//	APP_PATTERN='${literal}'        # single-quoted values are literal
+//	APP_CERT="-----BEGIN CERTIFICATE-----
+//	...
+//	-----END CERTIFICATE-----"      # quoted values may span multiple lines
+//
+// References ($VAR, ${VAR}, ${VAR:-default}, ${VAR-default}) are resolved from the process environment,
+// and from variables defined earlier, unresolved ones are replaced with an empty string.
+
+// LoadEnvFiles reads variables from dotenv files, a variable of a later file overrides the one of an earlier file
+func LoadEnvFiles(filenames []string) (map[string]string, error) {
+	return LoadEnvFilesWithKey(filenames, nil)
+}
+
+// LoadEnvFilesWithKey reads variables from dotenv files, encrypted files are decrypted in memory with the key
+func LoadEnvFilesWithKey(filenames []string, key []byte) (map[string]string, error) {
+	vars := make(map[string]string)
+	for _, filename := range filenames {
+		filename = strings.TrimSpace(filename)
+		if filename == "" {
+			continue
+		}
+		if err := loadEnvFile(filename, key, vars); err != nil {
+			return nil, err
+		}
+	}
+	return vars, nil
+}
+
+// loadEnvFile reads variables of a dotenv file into vars, an encrypted file is decrypted with the key
+func loadEnvFile(filename string, key []byte, vars map[string]string) error {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	if IsEncrypted(content) {
+		if key == nil {
+			return fmt.Errorf("%s: the file is encrypted, a decryption key is required", filename)
+		}
+		content, err = Decrypt(key, content)
+		if err != nil {
+			return fmt.Errorf("%s: %w", filename, err)
+		}
+	}
+	return parseDotenv(string(content), filename, vars)
+}
+
+// dotenvParser parses the content of a dotenv file into vars
+type dotenvParser struct {
+	*scanner
+	pos  int
+	vars map[string]string
+}
+
+// parseDotenv parses the content of a dotenv file, variables are added to vars
+func parseDotenv(content, filename string, vars map[string]string) error {
+	p := &dotenvParser{scanner: newScanner(content, filename), vars: vars}
+	for {
+		p.skipBlank()
+		if p.pos >= len(p.input) {
+			return nil
+		}
+		if err := p.entry(); err != nil {
+			return err
+		}
+	}
+}
+
+// entry parses a single assignment: [export] KEY=VALUE
+func (p *dotenvParser) entry() error {
+	start := p.pos
+	if strings.HasPrefix(p.input[p.pos:], "export ") || strings.HasPrefix(p.input[p.pos:], "export\t") {
+		p.pos += len("export")
+		p.skipSpaces()
+	}
+
+	keyStart := p.pos
+	if p.pos >= len(p.input) || !isNameStart(p.input[p.pos]) {
+		return fmt.Errorf("%s: expected a variable name", p.position(start))
+	}
+	p.pos = p.scanName(p.pos)
+	key := p.input[keyStart:p.pos]
+
+	p.skipSpaces()
+	if p.pos >= len(p.input) || p.input[p.pos] != '=' {
+		return fmt.Errorf("%s: expected '=' after %s", p.position(p.pos), key)
+	}
+	p.pos++
+	p.skipSpaces()
+
+	value, err := p.value()
+	if err != nil {
+		return err
+	}
+	p.vars[key] = value
+	return nil
+}
+
+// value parses a quoted or unquoted value, and the rest of its line
+func (p *dotenvParser) value() (string, error) {
+	if p.pos < len(p.input) && (p.input[p.pos] == '\'' || p.input[p.pos] == '"') {
+		quote := p.input[p.pos]
+		start := p.pos
+		end := p.closingQuote(start)
+		if end < 0 {
+			return "", fmt.Errorf("%s: unterminated quoted value, missing %c", p.position(start), quote)
+		}
+		raw := p.input[start+1 : end]
+		p.pos = end + 1
+
+		// only whitespace and a comment may follow a quoted value
+		p.skipSpaces()
+		if p.pos < len(p.input) && p.input[p.pos] != '#' && p.input[p.pos] != '\n' && p.input[p.pos] != '\r' {
+			return "", fmt.Errorf("%s: unexpected character %q after quoted value", p.position(p.pos), p.input[p.pos])
+		}
+		p.skipLine()
+
+		if quote == '\'' {
+			return raw, nil
+		}
+		return expandDotenv(raw, true, p.lookup), nil
+	}
+
+	// unquoted value: the rest of the line, without an inline comment
+	start := p.pos
+	p.skipLine()
+	raw := p.input[start:p.pos]
+	for i := 0; i < len(raw); i++ {
+		if raw[i] == '#' && (i == 0 || raw[i-1] == ' ' || raw[i-1] == '\t') {
+			raw = raw[:i]
+			break
+		}
+	}
+	return expandDotenv(strings.TrimSpace(raw), false, p.lookup), nil
+}
+
+// closingQuote returns the offset of a closing quote, backslash escapes are allowed in double quotes only
+func (p *dotenvParser) closingQuote(start int) int {
+	quote := p.input[start]
+	for i := start + 1; i < len(p.input); i++ {
+		switch {
+		case p.input[i] == '\\' && quote == '"':
+			i++
+		case p.input[i] == quote:
+			return i
+		}
+	}
+	return -1
+}
+
+// lookup resolves a reference, the process environment takes precedence over variables defined earlier
+func (p *dotenvParser) lookup(name string) (string, bool) {
+	if value, ok := os.LookupEnv(name); ok {
+		return value, true
+	}
+	value, ok := p.vars[name]
+	return value, ok
+}
+
+// skipBlank skips whitespace, empty lines and comments
+func (p *dotenvParser) skipBlank() {
+	for p.pos < len(p.input) {
+		switch p.input[p.pos] {
+		case ' ', '\t', '\r', '\n':
+			p.pos++
+		case '#':
+			p.skipLine()
+		default:
+			return
+		}
+	}
+}
+
+func (p *dotenvParser) skipSpaces() {
+	for p.pos < len(p.input) && (p.input[p.pos] == ' ' || p.input[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// skipLine moves to the end of the current line
+func (p *dotenvParser) skipLine() {
+	for p.pos < len(p.input) && p.input[p.pos] != '\n' {
+		p.pos++
+	}
+	if p.pos > 0 && p.pos <= len(p.input) && p.input[p.pos-1] == '\r' {
+		p.pos--
+	}
+}
+
+// expandDotenv expands references in a value, and escape sequences when escapes is set
+func expandDotenv(value string, escapes bool, lookup func(string) (string, bool)) string {
+	var sb strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+
+		if escapes && c == '\\' && i+1 < len(value) {
+			i++
+			switch value[i] {
+			case 'n':
+				sb.WriteByte('\n')
+			case 'r':
+				sb.WriteByte('\r')
+			case 't':
+				sb.WriteByte('\t')
+			case '\\', '"', '$':
+				sb.WriteByte(value[i])
+			default:
+				sb.WriteByte('\\')
+				sb.WriteByte(value[i])
+			}
+			continue
+		}
+
+		if c != '$' || i+1 >= len(value) {
+			sb.WriteByte(c)
+			continue
+		}
+
+		// plain reference: $VAR
+		if isNameStart(value[i+1]) {
+			end := i + 1
+			for end < len(value) && isNameChar(value[end]) {
+				end++
+			}
+			resolved, _ := lookup(value[i+1 : end])
+			sb.WriteString(resolved)
+			i = end - 1
+			continue
+		}
+
+		// braced reference: ${VAR}, ${VAR:-default}, ${VAR-default}
+		if value[i+1] == '{' {
+			if expanded, end, ok := expandDotenvBraced(value, i, escapes, lookup); ok {
+				sb.WriteString(expanded)
+				i = end - 1
+				continue
+			}
+		}
+
+		sb.WriteByte(c)
+	}
+	return sb.String()
+}
+
+// expandDotenvBraced expands a braced reference at the offset, it returns the offset after the reference
+func expandDotenvBraced(value string, offset int, escapes bool, lookup func(string) (string, bool)) (string, int, bool) {
+	nameStart := offset + 2
+	nameEnd := nameStart
+	for nameEnd < len(value) && isNameChar(value[nameEnd]) {
+		nameEnd++
+	}
+	if nameEnd == nameStart || !isNameStart(value[nameStart]) || nameEnd >= len(value) {
+		return "", 0, false
+	}
+
+	// find the closing brace, nested references are allowed in defaults
+	depth := 1
+	closing := nameEnd
+	for ; closing < len(value); closing++ {
+		if value[closing] == '{' && value[closing-1] == '$' {
+			depth++
+		}
+		if value[closing] == '}' {
+			depth--
+			if depth == 0 {
+				break
+			}
+		}
+	}
+	if closing >= len(value) {
+		return "", 0, false
+	}
+
+	resolved, isSet := lookup(value[nameStart:nameEnd])
+	rest := value[nameEnd:closing]
+	switch {
+	case rest == "":
+		return resolved, closing + 1, true
+	case strings.HasPrefix(rest, ":-"):
+		if !isSet || resolved == "" {
+			resolved = expandDotenv(rest[2:], escapes, lookup)
+		}
+		return resolved, closing + 1, true
+	case strings.HasPrefix(rest, "-"):
+		if !isSet {
+			resolved = expandDotenv(rest[1:], escapes, lookup)
+		}
+		return resolved, closing + 1, true
+	}
+	return "", 0, false
+}
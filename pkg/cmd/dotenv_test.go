@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseDotenv(t *testing.T) {
+	os.Setenv("DOTENV_TEST_USER", "admin")
+	defer os.Unsetenv("DOTENV_TEST_USER")
+
+	content := `# comment
+APP_NAME=my-app
+export APP_IMAGE = registry.local/app:1.0  # inline comment
+APP_URL=http://example.com/#anchor
+APP_EMPTY=
+APP_SINGLE='${literal} \n # not a comment'
+APP_DOUBLE="Hello,\t\"${DOTENV_TEST_USER}\"\n\$HOME"
+APP_MULTILINE="line1
+line2"
+APP_PEM='-----BEGIN-----
+abc
+-----END-----'   # comment
+APP_REF=${APP_NAME}-$APP_NAME
+APP_DEFAULT=${APP_UNSET:-default}-${APP_EMPTY-unset}-${APP_UNSET:-${APP_NAME}}
+APP_UNRESOLVED=x${APP_UNSET}x
+APP_DOLLAR=100$ $1 ${
+	APP_INDENTED = value
+`
+	vars := map[string]string{}
+	if err := parseDotenv(content, ".env", vars); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := map[string]string{
+		"APP_NAME":       "my-app",
+		"APP_IMAGE":      "registry.local/app:1.0",
+		"APP_URL":        "http://example.com/#anchor",
+		"APP_EMPTY":      "",
+		"APP_SINGLE":     `${literal} \n # not a comment`,
+		"APP_DOUBLE":     "Hello,\t\"admin\"\n$HOME",
+		"APP_MULTILINE":  "line1\nline2",
+		"APP_PEM":        "-----BEGIN-----\nabc\n-----END-----",
+		"APP_REF":        "my-app-my-app",
+		"APP_DEFAULT":    "default--my-app",
+		"APP_UNRESOLVED": "xx",
+		"APP_DOLLAR":     "100$ $1 ${",
+		"APP_INDENTED":   "value",
+	}
+	if !reflect.DeepEqual(vars, expected) {
+		t.Errorf("Expected %v, got %v", expected, vars)
+	}
+}
+
+func TestParseDotenv_CRLF(t *testing.T) {
+	vars := map[string]string{}
+	if err := parseDotenv("A=1\r\nB=\"2\"\r\nC='3' # c\r\n", ".env", vars); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := map[string]string{"A": "1", "B": "2", "C": "3"}
+	if !reflect.DeepEqual(vars, expected) {
+		t.Errorf("Expected %v, got %v", expected, vars)
+	}
+}
+
+func TestParseDotenv_Errors(t *testing.T) {
+	tests := []struct {
+		name          string
+		content       string
+		expectedError string
+	}{
+		{name: "Missing name", content: "=value", expectedError: ".env:1:1: expected a variable name"},
+		{name: "Invalid name", content: "A=1\n1A=2", expectedError: ".env:2:1: expected a variable name"},
+		{name: "Missing equals", content: "A=1\nexport B\n", expectedError: ".env:2:9: expected '=' after B"},
+		{name: "Unterminated quote", content: "A=\"value\nB=2", expectedError: ".env:1:3: unterminated quoted value, missing \""},
+		{name: "Text after quote", content: "A='value' tail", expectedError: ".env:1:11: unexpected character 't' after quoted value"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := parseDotenv(test.content, ".env", map[string]string{})
+			if err == nil || err.Error() != test.expectedError {
+				t.Errorf("Expected error '%s', got '%v'", test.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestLoadEnvFiles(t *testing.T) {
+	dir := t.TempDir()
+	common := filepath.Join(dir, "common.env")
+	prod := filepath.Join(dir, "prod.env")
+	if err := os.WriteFile(common, []byte("APP_ENV=dev\nAPP_HOST=db.${APP_ENV}\nAPP_PORT=5432\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(prod, []byte("APP_ENV=prod\nAPP_HOST=db.${APP_ENV}:${APP_PORT}\nAPP_USER=${DOTENV_TEST_USER}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("DOTENV_TEST_USER", "admin")
+	defer os.Unsetenv("DOTENV_TEST_USER")
+
+	vars, err := LoadEnvFiles([]string{common, "", prod})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// later files override earlier ones, references are resolved from variables defined earlier
+	expected := map[string]string{
+		"APP_ENV":  "prod",
+		"APP_HOST": "db.prod:5432",
+		"APP_PORT": "5432",
+		"APP_USER": "admin",
+	}
+	if !reflect.DeepEqual(vars, expected) {
+		t.Errorf("Expected %v, got %v", expected, vars)
+	}
+
+	if _, err := LoadEnvFiles([]string{filepath.Join(dir, "missing.env")}); err == nil {
+		t.Error("Expected an error for a missing file, but got none")
+	}
+}
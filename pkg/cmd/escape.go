@@ -0,0 +1,760 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Substituted values are escaped according to the context of a placeholder, so any value
+// produces the intended scalar:
+//
+//	plain scalar           the whole scalar is double-quoted, when the value would break it: "a: b", "#x", "*x",
+//	                       including its continuation lines, that are folded
+//	single-quoted scalar   quotes are doubled, the scalar is double-quoted when the value has line breaks,
+//	                       its own line breaks are folded
+//	double-quoted scalar   JSON string escaping, that is a subset of YAML escaping
+//	block scalar           continuation lines of the value are indented to the column of the placeholder,
+//	                       line breaks are doubled in folded scalars, an indentation indicator is added,
+//	                       when the first line of the scalar starts with whitespace of the value
+//	comment                the value is inserted as is
+//
+// For JSON files only strings are escaped. A placeholder that takes a whole line is inserted as is,
+// since it is usually a fragment of a document, the autoindent filter indents its continuation lines.
+
+// scalarStyle is a context of a placeholder in a document
+type scalarStyle int
+
+const (
+	stylePlain scalarStyle = iota
+	styleSingleQuoted
+	styleDoubleQuoted
+	styleBlock
+	styleComment
+)
+
+// maskChar replaces placeholders before analysis, so their content is never taken for YAML syntax
+const maskChar = '\x00'
+
+// scalarSpan is a range of the input, occupied by a scalar or a comment, quoted scalars include quotes
+type scalarSpan struct {
+	start int
+	end   int
+	style scalarStyle
+	// flow is set for plain scalars inside of flow collections: [a, b], {a: b}
+	flow bool
+	// folded is set for lines of folded block scalars: >
+	folded bool
+	// header is the offset after the indicator of a block scalar (| or >), where an indentation indicator
+	// may be inserted, it is -1 when the header already has one
+	header int
+	// indent is the indentation of lines of a block scalar relative to its parent node
+	indent int
+	// first is set for the first content line of a block scalar, that determines its indentation
+	first bool
+}
+
+// pieceKind tells how a piece of the output is related to the input
+type pieceKind int
+
+const (
+	// pieceLiteral is a copy of the input
+	pieceLiteral pieceKind = iota
+	// pieceValue is a substituted value, that is escaped according to its context
+	pieceValue
+	// pieceVerbatim is a substituted value, that is inserted as is
+	pieceVerbatim
+	// pieceIndented is a substituted value, that is inserted with continuation lines indented
+	// to the column of the placeholder
+	pieceIndented
+)
+
+// piece is a part of the output, produced by a token
+type piece struct {
+	kind  pieceKind
+	start int
+	end   int
+	text  string
+}
+
+// analyzeContexts returns scalar spans of the input, placeholders are masked
+func analyzeContexts(input string, tokens []token, isJSON bool) []scalarSpan {
+	masked := []byte(input)
+	for i := range tokens {
+		t := &tokens[i]
+		if t.kind != tokenText {
+			for j := t.offset; j < t.offset+len(t.raw); j++ {
+				masked[j] = maskChar
+			}
+		}
+	}
+
+	if isJSON {
+		return analyzeJSON(string(masked))
+	}
+	a := &yamlAnalyzer{input: string(masked)}
+	a.analyze()
+	return a.spans
+}
+
+// escapeValues joins pieces of the output, substituted values are escaped according to their spans
+func escapeValues(input string, pieces []piece, spans []scalarSpan) string {
+	// indentation indicators of block scalars by offsets of their headers
+	indicators := map[int]int{}
+	for i := range pieces {
+		pc := &pieces[i]
+		atLineEnd := isLineEnd(input, pc.end)
+		// continuation lines of a value, that starts inside of a line, are more-indented, so they are never folded
+		if span, ok := spanAt(spans, pc.start); ok && span.style == styleBlock && pc.kind == pieceValue && pc.start == span.start {
+			if span.folded {
+				pc.text = foldLineBreaks(pc.text, atLineEnd)
+			}
+			if span.first && span.header >= 0 && span.indent > 0 && span.indent < 10 && isMoreIndented(strings.TrimLeft(pc.text, "\r\n")) {
+				indicators[span.header] = span.indent
+			}
+		}
+		if pc.kind == pieceIndented || (pc.kind == pieceValue && inBlockScalar(spans, pc.start)) {
+			indentation := indentationAt(input, pc.start)
+			pc.text = reindent(pc.text, indentation)
+			// the text after a value, that ends with a line break, continues its last line
+			if !atLineEnd && strings.HasSuffix(pc.text, "\n") {
+				pc.text += indentation
+			}
+		}
+	}
+
+	// spans, that contain substituted values
+	valueSpans := []scalarSpan{}
+	for _, pc := range pieces {
+		if pc.kind != pieceValue {
+			continue
+		}
+		span, ok := spanAt(spans, pc.start)
+		if ok && (len(valueSpans) == 0 || valueSpans[len(valueSpans)-1] != span) {
+			valueSpans = append(valueSpans, span)
+		}
+	}
+
+	// split literal pieces at boundaries of spans
+	split := make([]piece, 0, len(pieces))
+	for _, pc := range pieces {
+		if pc.kind != pieceLiteral {
+			split = append(split, pc)
+			continue
+		}
+		cuts := []int{}
+		for _, span := range valueSpans {
+			cuts = append(cuts, span.start, span.end)
+		}
+		for header := range indicators {
+			cuts = append(cuts, header)
+		}
+		sort.Ints(cuts)
+		start := pc.start
+		for _, cut := range cuts {
+			if cut > start && cut < pc.end {
+				split = append(split, piece{kind: pieceLiteral, start: start, end: cut, text: input[start:cut]})
+				start = cut
+			}
+		}
+		split = append(split, piece{kind: pieceLiteral, start: start, end: pc.end, text: input[start:pc.end]})
+	}
+
+	var sb strings.Builder
+	k := 0
+	for i := 0; i < len(split); {
+		for k < len(valueSpans) && valueSpans[k].end <= split[i].start {
+			k++
+		}
+		if k < len(valueSpans) && split[i].start >= valueSpans[k].start {
+			j := i
+			for j < len(split) && split[j].end <= valueSpans[k].end {
+				j++
+			}
+			sb.WriteString(encodeSpan(valueSpans[k], split[i:j]))
+			i = j
+			continue
+		}
+		if indent, ok := indicators[split[i].start]; ok && split[i].kind == pieceLiteral {
+			sb.WriteByte(byte('0' + indent))
+		}
+		sb.WriteString(split[i].text)
+		i++
+	}
+	return sb.String()
+}
+
+// spanAt returns a span that contains the offset
+func spanAt(spans []scalarSpan, offset int) (scalarSpan, bool) {
+	i := sort.Search(len(spans), func(i int) bool {
+		return spans[i].end > offset
+	})
+	if i < len(spans) && spans[i].start <= offset {
+		return spans[i], true
+	}
+	return scalarSpan{}, false
+}
+
+func inBlockScalar(spans []scalarSpan, offset int) bool {
+	span, ok := spanAt(spans, offset)
+	return ok && span.style == styleBlock
+}
+
+// indentationAt returns whitespace, that aligns a text with the offset: tabs of the line are kept,
+// other characters are replaced with spaces
+func indentationAt(input string, offset int) string {
+	lineStart := strings.LastIndexByte(input[:offset], '\n') + 1
+	var sb strings.Builder
+	for _, r := range input[lineStart:offset] {
+		if r == '\t' {
+			sb.WriteRune(r)
+		} else {
+			sb.WriteByte(' ')
+		}
+	}
+	return sb.String()
+}
+
+// reindent prefixes continuation lines of a value with the indentation, empty lines remain empty
+func reindent(value, indentation string) string {
+	if indentation == "" || !strings.Contains(value, "\n") {
+		return value
+	}
+	lines := strings.Split(value, "\n")
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimRight(lines[i], "\r") != "" {
+			lines[i] = indentation + lines[i]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// foldLineBreaks adds a line break to every run of line breaks of a value, that starts a line of
+// a folded block scalar, where a single line break between lines becomes a space. Line breaks around
+// more-indented lines are not folded, so they remain as is. atLineEnd tells whether the last line
+// of the value is a whole line of the scalar.
+func foldLineBreaks(value string, atLineEnd bool) string {
+	lines := strings.Split(value, "\n")
+	var sb strings.Builder
+	sb.WriteString(lines[0])
+	// a previous non-empty line, that is folded with the next one
+	hasPrev := strings.TrimRight(lines[0], "\r") != ""
+	prevIndented := isMoreIndented(lines[0])
+	for i, line := range lines[1:] {
+		content := strings.TrimRight(line, "\r") != "" || (i == len(lines)-2 && !atLineEnd)
+		if content && hasPrev && !prevIndented && !isMoreIndented(line) {
+			sb.WriteByte('\n')
+		}
+		sb.WriteByte('\n')
+		sb.WriteString(line)
+		if content {
+			hasPrev, prevIndented = true, isMoreIndented(line)
+		}
+	}
+	return sb.String()
+}
+
+// isLineEnd checks whether only whitespace follows the offset on its line
+func isLineEnd(input string, offset int) bool {
+	end := strings.IndexByte(input[offset:], '\n')
+	if end < 0 {
+		end = len(input) - offset
+	}
+	return strings.TrimRight(input[offset:offset+end], " \t\r") == ""
+}
+
+// isMoreIndented checks whether a line of a block scalar starts with whitespace
+func isMoreIndented(line string) bool {
+	return line != "" && isSpaceByte(line[0])
+}
+
+// encodeSpan joins pieces of a span, escaping substituted values
+func encodeSpan(span scalarSpan, pieces []piece) string {
+	var sb strings.Builder
+
+	switch span.style {
+	case styleDoubleQuoted:
+		for _, pc := range pieces {
+			if pc.kind == pieceValue {
+				sb.WriteString(escapeJSONString(pc.text))
+			} else {
+				sb.WriteString(pc.text)
+			}
+		}
+		return sb.String()
+
+	case styleSingleQuoted:
+		if !hasMultilineValue(pieces) {
+			for _, pc := range pieces {
+				if pc.kind == pieceValue {
+					sb.WriteString(strings.ReplaceAll(pc.text, "'", "''"))
+				} else {
+					sb.WriteString(pc.text)
+				}
+			}
+			return sb.String()
+		}
+		// a line break cannot be kept in a single-quoted scalar as is, it is double-quoted instead,
+		// line breaks of the scalar itself are folded
+		for i, pc := range pieces {
+			text := pc.text
+			if pc.kind != pieceValue {
+				if i == 0 {
+					text = text[1:]
+				}
+				if i == len(pieces)-1 {
+					text = text[:len(text)-1]
+				}
+				text = foldFlowLines(strings.ReplaceAll(text, "''", "'"))
+			}
+			sb.WriteString(text)
+		}
+		return quoteJSONString(sb.String())
+
+	case stylePlain:
+		for _, pc := range pieces {
+			sb.WriteString(pc.text)
+		}
+		if !hasLiteralLineBreak(pieces) {
+			if needsQuoting(sb.String(), span.flow) {
+				return quoteJSONString(sb.String())
+			}
+			return sb.String()
+		}
+		// a scalar, that spans several lines, is checked and quoted as a whole with its line breaks folded
+		var folded strings.Builder
+		for _, pc := range pieces {
+			if pc.kind == pieceValue {
+				folded.WriteString(pc.text)
+			} else {
+				folded.WriteString(foldFlowLines(pc.text))
+			}
+		}
+		if needsQuoting(folded.String(), span.flow) {
+			return quoteJSONString(folded.String())
+		}
+		return sb.String()
+	}
+
+	for _, pc := range pieces {
+		sb.WriteString(pc.text)
+	}
+	return sb.String()
+}
+
+func hasMultilineValue(pieces []piece) bool {
+	for _, pc := range pieces {
+		if pc.kind == pieceValue && strings.ContainsAny(pc.text, "\r\n") {
+			return true
+		}
+	}
+	return false
+}
+
+func hasLiteralLineBreak(pieces []piece) bool {
+	for _, pc := range pieces {
+		if pc.kind != pieceValue && strings.ContainsAny(pc.text, "\r\n") {
+			return true
+		}
+	}
+	return false
+}
+
+// foldFlowLines folds line breaks of a text of a quoted or a plain scalar the way YAML does:
+// whitespace around line breaks is removed, a single line break becomes a space,
+// a line break followed by empty lines becomes line breaks, one per empty line
+func foldFlowLines(text string) string {
+	lines := strings.Split(text, "\n")
+	if len(lines) == 1 {
+		return text
+	}
+	var sb strings.Builder
+	sb.WriteString(strings.TrimRight(lines[0], " \t\r"))
+	empty := 0
+	for i, line := range lines[1:] {
+		last := i == len(lines)-2
+		if !last {
+			line = strings.TrimRight(line, " \t\r")
+		}
+		line = strings.TrimLeft(line, " \t")
+		if line == "" && !last {
+			empty++
+			continue
+		}
+		if empty == 0 {
+			sb.WriteByte(' ')
+		} else {
+			sb.WriteString(strings.Repeat("\n", empty))
+		}
+		empty = 0
+		sb.WriteString(line)
+	}
+	return sb.String()
+}
+
+// needsQuoting checks whether a text cannot be a plain scalar, or would be parsed as something else
+func needsQuoting(text string, flow bool) bool {
+	if text == "" {
+		return false
+	}
+	if strings.TrimSpace(text) != text {
+		return true
+	}
+	for _, r := range text {
+		if r == '\n' || r == '\r' || (unicode.IsControl(r) && r != '\t') {
+			return true
+		}
+	}
+
+	first := text[0]
+	if strings.IndexByte("[]{},#&*!|>'\"%@`", first) >= 0 {
+		return true
+	}
+	if strings.IndexByte("-?:", first) >= 0 && (len(text) == 1 || text[1] == ' ' || text[1] == '\t') {
+		return true
+	}
+	if strings.Contains(text, ": ") || strings.Contains(text, ":\t") || strings.HasSuffix(text, ":") {
+		return true
+	}
+	if strings.Contains(text, " #") || strings.Contains(text, "\t#") {
+		return true
+	}
+	return flow && strings.ContainsAny(text, ",[]{}")
+}
+
+// quoteJSONString returns a double-quoted string, that is valid both in JSON and YAML
+func quoteJSONString(text string) string {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(text); err != nil {
+		return text
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// escapeJSONString escapes a text for a double-quoted string, without quotes
+func escapeJSONString(text string) string {
+	quoted := quoteJSONString(text)
+	return quoted[1 : len(quoted)-1]
+}
+
+// analyzeJSON returns spans of strings in a JSON document
+func analyzeJSON(input string) []scalarSpan {
+	spans := []scalarSpan{}
+	for i := 0; i < len(input); i++ {
+		if input[i] != '"' {
+			continue
+		}
+		start := i
+		for i++; i < len(input) && input[i] != '"'; i++ {
+			if input[i] == '\\' {
+				i++
+			}
+		}
+		spans = append(spans, scalarSpan{start: start, end: min(i+1, len(input)), style: styleDoubleQuoted})
+	}
+	return spans
+}
+
+// yamlAnalyzer finds scalars and comments in a YAML document.
+// It is not a complete parser, only contexts of scalars are recognized.
+type yamlAnalyzer struct {
+	input string
+	pos   int
+	spans []scalarSpan
+}
+
+func (a *yamlAnalyzer) analyze() {
+	for a.pos < len(a.input) {
+		a.line()
+	}
+}
+
+// line analyzes a line, that starts at the current position
+func (a *yamlAnalyzer) line() {
+	lineStart := a.pos
+	a.skipSpaces()
+	rest := a.input[a.pos:a.lineEnd()]
+
+	switch {
+	case strings.TrimSpace(rest) == "":
+	case lineStart == a.pos && (strings.HasPrefix(rest, "---") || strings.HasPrefix(rest, "...") || rest[0] == '%'):
+		// document markers and directives
+	case strings.Trim(rest, string(maskChar)+" \t\r") == "":
+		// a placeholder that takes a whole line is inserted as is
+	default:
+		if a.blockNode(a.pos - lineStart) {
+			// a block scalar stops at the beginning of the next line
+			return
+		}
+	}
+	a.nextLine()
+}
+
+// blockNode analyzes nodes on the rest of a line in a block context,
+// parent is the indentation of a node, that owns a block scalar.
+// It returns true, when a block scalar was analyzed till the beginning of a next line.
+func (a *yamlAnalyzer) blockNode(parent int) bool {
+	lineStart := a.pos - parent
+	for {
+		a.skipSpaces()
+		if a.atLineEnd() {
+			return false
+		}
+
+		c := a.input[a.pos]
+		switch {
+		case c == '#':
+			a.comment()
+			return false
+		case (c == '-' || c == '?' || c == ':') && a.isSeparatedAt(a.pos+1):
+			// sequence entry, complex key or value indicators
+			if c == '-' {
+				parent = a.pos - lineStart
+			}
+			a.pos++
+		case c == '&' || c == '!':
+			// anchors and tags
+			for !a.atLineEnd() && !isSpaceByte(a.input[a.pos]) {
+				a.pos++
+			}
+		case c == '|' || c == '>':
+			a.blockScalar(parent)
+			return true
+		case c == '"' || c == '\'':
+			start := a.pos
+			a.quoted(c)
+			if a.isKeyIndicator() {
+				parent = start - lineStart
+				a.pos++
+			}
+		case c == '[' || c == '{':
+			a.flow()
+		default:
+			start := a.pos
+			a.plain(false)
+			if a.isKeyIndicator() {
+				parent = start - lineStart
+				a.pos++
+			} else if a.atLineEnd() {
+				// a scalar, that starts a line, may continue on lines of the same indentation
+				if start-lineStart == parent {
+					parent--
+				}
+				a.plainLines(parent)
+			}
+		}
+	}
+}
+
+// blockScalar skips a header of a block scalar and adds content lines, that are indented more than parent
+func (a *yamlAnalyzer) blockScalar(parent int) {
+	folded := a.input[a.pos] == '>'
+	a.pos++
+	header := a.pos
+	for !a.atLineEnd() && a.input[a.pos] != '#' {
+		if a.input[a.pos] >= '1' && a.input[a.pos] <= '9' {
+			header = -1
+		}
+		a.pos++
+	}
+	if !a.atLineEnd() {
+		a.comment()
+	}
+	a.nextLine()
+
+	first := true
+	for a.pos < len(a.input) {
+		lineStart := a.pos
+		a.skipSpaces()
+		end := a.lineEnd()
+		if strings.TrimSpace(a.input[a.pos:end]) != "" {
+			if a.pos-lineStart <= parent {
+				a.pos = lineStart
+				return
+			}
+			a.spans = append(a.spans, scalarSpan{
+				start:  a.pos,
+				end:    end,
+				style:  styleBlock,
+				folded: folded,
+				header: header,
+				indent: a.pos - lineStart - parent,
+				first:  first,
+			})
+			first = false
+		}
+		a.pos = end
+		a.nextLine()
+	}
+}
+
+// flow analyzes a flow collection, that may span several lines
+func (a *yamlAnalyzer) flow() {
+	depth := 0
+	for a.pos < len(a.input) {
+		c := a.input[a.pos]
+		switch {
+		case c == '[' || c == '{':
+			depth++
+			a.pos++
+		case c == ']' || c == '}':
+			depth--
+			a.pos++
+			if depth == 0 {
+				return
+			}
+		case c == ',' || isSpaceByte(c) || c == '\n' || c == '\r':
+			a.pos++
+		case c == '#' && (a.pos == 0 || isSpaceByte(a.input[a.pos-1]) || a.input[a.pos-1] == '\n'):
+			a.comment()
+		case c == '"' || c == '\'':
+			a.quoted(c)
+		case (c == ':' || c == '?') && a.isFlowSeparatedAt(a.pos+1):
+			a.pos++
+		case c == '&' || c == '!':
+			for a.pos < len(a.input) && !isSpaceByte(a.input[a.pos]) && a.input[a.pos] != '\n' {
+				a.pos++
+			}
+		default:
+			a.plain(true)
+		}
+	}
+}
+
+// plain adds a plain scalar, that ends at a line end, a comment, or a mapping value indicator
+func (a *yamlAnalyzer) plain(flow bool) {
+	start := a.pos
+	for !a.atLineEnd() {
+		c := a.input[a.pos]
+		if c == ':' && (a.isSeparatedAt(a.pos+1) || (flow && a.isFlowSeparatedAt(a.pos+1))) {
+			break
+		}
+		if c == '#' && a.pos > start && isSpaceByte(a.input[a.pos-1]) {
+			break
+		}
+		if flow && strings.IndexByte(",[]{}", c) >= 0 {
+			break
+		}
+		a.pos++
+	}
+	end := a.pos
+	for end > start && (isSpaceByte(a.input[end-1]) || a.input[end-1] == '\r') {
+		end--
+	}
+	if end > start {
+		a.spans = append(a.spans, scalarSpan{start: start, end: end, style: stylePlain, flow: flow})
+	}
+}
+
+// plainLines extends the last plain scalar over continuation lines, that are indented more than parent,
+// the position remains at the end of the last line of the scalar
+func (a *yamlAnalyzer) plainLines(parent int) {
+	span := len(a.spans) - 1
+	last := a.pos
+	for a.pos < len(a.input) {
+		a.nextLine()
+		lineStart := a.pos
+		a.skipSpaces()
+		rest := strings.TrimRight(a.input[a.pos:a.lineEnd()], " \t\r")
+		if rest == "" {
+			// empty lines are folded into the scalar
+			continue
+		}
+		if a.pos-lineStart <= parent || rest[0] == '#' || strings.Trim(rest, string(maskChar)) == "" ||
+			(a.pos == lineStart && (strings.HasPrefix(rest, "---") || strings.HasPrefix(rest, "..."))) {
+			break
+		}
+		a.plain(false)
+		a.spans[span].end = a.spans[len(a.spans)-1].end
+		a.spans = a.spans[:len(a.spans)-1]
+		if !a.atLineEnd() {
+			// a comment ends the scalar
+			return
+		}
+		last = a.pos
+	}
+	a.pos = last
+}
+
+// quoted adds a quoted scalar, that may span several lines
+func (a *yamlAnalyzer) quoted(quote byte) {
+	start := a.pos
+	style := styleDoubleQuoted
+	if quote == '\'' {
+		style = styleSingleQuoted
+	}
+
+	for a.pos++; a.pos < len(a.input); a.pos++ {
+		c := a.input[a.pos]
+		if quote == '"' && c == '\\' {
+			a.pos++
+			continue
+		}
+		if c == quote {
+			if quote == '\'' && a.pos+1 < len(a.input) && a.input[a.pos+1] == '\'' {
+				a.pos++
+				continue
+			}
+			a.pos++
+			break
+		}
+	}
+	a.pos = min(a.pos, len(a.input))
+	a.spans = append(a.spans, scalarSpan{start: start, end: a.pos, style: style})
+}
+
+// comment adds a comment till the line end
+func (a *yamlAnalyzer) comment() {
+	start := a.pos
+	a.pos = a.lineEnd()
+	a.spans = append(a.spans, scalarSpan{start: start, end: a.pos, style: styleComment})
+}
+
+// isKeyIndicator checks whether a mapping value indicator follows the current position
+func (a *yamlAnalyzer) isKeyIndicator() bool {
+	a.skipSpaces()
+	return a.pos < len(a.input) && a.input[a.pos] == ':' && a.isSeparatedAt(a.pos+1)
+}
+
+// isSeparatedAt checks whether a whitespace or a line end is at the offset
+func (a *yamlAnalyzer) isSeparatedAt(offset int) bool {
+	return offset >= len(a.input) || isSpaceByte(a.input[offset]) || a.input[offset] == '\n' || a.input[offset] == '\r'
+}
+
+// isFlowSeparatedAt checks whether a whitespace, a line end or a flow indicator is at the offset
+func (a *yamlAnalyzer) isFlowSeparatedAt(offset int) bool {
+	return a.isSeparatedAt(offset) || strings.IndexByte(",[]{}", a.input[offset]) >= 0
+}
+
+func (a *yamlAnalyzer) skipSpaces() {
+	for a.pos < len(a.input) && isSpaceByte(a.input[a.pos]) {
+		a.pos++
+	}
+}
+
+func (a *yamlAnalyzer) atLineEnd() bool {
+	return a.pos >= len(a.input) || a.input[a.pos] == '\n'
+}
+
+func (a *yamlAnalyzer) lineEnd() int {
+	if idx := strings.IndexByte(a.input[a.pos:], '\n'); idx >= 0 {
+		return a.pos + idx
+	}
+	return len(a.input)
+}
+
+// nextLine moves the position to the beginning of the next line
+func (a *yamlAnalyzer) nextLine() {
+	a.pos = a.lineEnd()
+	if a.pos < len(a.input) {
+		a.pos++
+	}
+}
+
+func isSpaceByte(c byte) bool {
+	return c == ' ' || c == '\t'
+}
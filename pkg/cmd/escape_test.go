@@ -0,0 +1,316 @@
+package cmd
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestNeedsQuoting(t *testing.T) {
+	tests := []struct {
+		text     string
+		flow     bool
+		expected bool
+	}{
+		{text: "nginx:1.27", expected: false},
+		{text: "http://example.com/#anchor", expected: false},
+		{text: "-1", expected: false},
+		{text: "3", expected: false},
+		{text: "a, b", expected: false},
+		{text: "", expected: false},
+		{text: "a: b", expected: true},
+		{text: "a:", expected: true},
+		{text: "a #b", expected: true},
+		{text: "#a", expected: true},
+		{text: "*alias", expected: true},
+		{text: "&anchor", expected: true},
+		{text: "!tag", expected: true},
+		{text: "- a", expected: true},
+		{text: "{a: b}", expected: true},
+		{text: "'a'", expected: true},
+		{text: " a", expected: true},
+		{text: "a\nb", expected: true},
+		{text: "a, b", flow: true, expected: true},
+		{text: "a]", flow: true, expected: true},
+	}
+
+	for _, test := range tests {
+		if result := needsQuoting(test.text, test.flow); result != test.expected {
+			t.Errorf("For %q (flow=%v), expected %v, got %v", test.text, test.flow, test.expected, result)
+		}
+	}
+}
+
+func TestAnalyzeContexts(t *testing.T) {
+	input := `# ${A}
+key: ${A}
+single: 'x ${A}'
+double: "x ${A}"
+block: |
+  x ${A}
+flow: [${A}, b]
+${A}
+- name: &app ${A} # ${A}
+`
+	tokens, err := newScanner(input, "").scanAll()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	spans := analyzeContexts(input, tokens, false)
+
+	expected := []struct {
+		style scalarStyle
+		flow  bool
+		found bool
+	}{
+		{style: styleComment, found: true},
+		{style: stylePlain, found: true},
+		{style: styleSingleQuoted, found: true},
+		{style: styleDoubleQuoted, found: true},
+		{style: styleBlock, found: true},
+		{style: stylePlain, flow: true, found: true},
+		{found: false},
+		{style: stylePlain, found: true},
+		{style: styleComment, found: true},
+	}
+
+	placeholders := []token{}
+	for _, token := range tokens {
+		if token.kind == tokenPlaceholder {
+			placeholders = append(placeholders, token)
+		}
+	}
+	if len(placeholders) != len(expected) {
+		t.Fatalf("Expected %d placeholders, got %d", len(expected), len(placeholders))
+	}
+
+	for i, token := range placeholders {
+		span, found := spanAt(spans, token.offset)
+		if found != expected[i].found {
+			t.Errorf("At %s, expected found=%v, got %v", token.pos, expected[i].found, found)
+			continue
+		}
+		if found && (span.style != expected[i].style || span.flow != expected[i].flow) {
+			t.Errorf("At %s, expected style %d (flow=%v), got %d (flow=%v)", token.pos, expected[i].style, expected[i].flow, span.style, span.flow)
+		}
+	}
+}
+
+func TestSubstituteEnvs_ContextEscaping(t *testing.T) {
+	os.Setenv("APP_COLON", "a: b")
+	os.Setenv("APP_HASH", "x #y")
+	os.Setenv("APP_ALIAS", "*ref")
+	os.Setenv("APP_QUOTES", `it's "q"`)
+	os.Setenv("APP_LINES", "line1\nline2")
+	os.Setenv("APP_SIMPLE", "nginx:1.27")
+	os.Setenv("APP_NUMBER", "3")
+	os.Setenv("APP_MULTI", "l1\nl2")
+	defer func() {
+		for _, e := range []string{"APP_COLON", "APP_HASH", "APP_ALIAS", "APP_QUOTES", "APP_LINES", "APP_SIMPLE", "APP_NUMBER", "APP_MULTI"} {
+			os.Unsetenv(e)
+		}
+	}()
+
+	tests := []struct {
+		name     string
+		filename string
+		input    string
+		expected string
+	}{
+		{name: "Plain, safe value", input: "image: ${APP_SIMPLE}", expected: "image: nginx:1.27"},
+		{name: "Plain, number", input: "replicas: $APP_NUMBER", expected: "replicas: 3"},
+		{name: "Plain, colon", input: "key: ${APP_COLON}", expected: `key: "a: b"`},
+		{name: "Plain, comment", input: "key: ${APP_HASH} # comment", expected: `key: "x #y" # comment`},
+		{name: "Plain, alias", input: "key: ${APP_ALIAS}", expected: `key: "*ref"`},
+		{name: "Plain, part of a scalar", input: "key: prefix-${APP_COLON}-suffix", expected: `key: "prefix-a: b-suffix"`},
+		{name: "Plain, multiline", input: "key: ${APP_LINES}", expected: `key: "line1\nline2"`},
+		{name: "Plain, multiline scalar", input: "a: multi\n  line ${APP_MULTI}\nb: c", expected: "a: \"multi line l1\\nl2\"\nb: c"},
+		{name: "Plain, multiline scalar, colon", input: "a: multi\n\n  line ${APP_COLON} # c\nb: c", expected: "a: \"multi\\nline a: b\" # c\nb: c"},
+		{name: "Plain, multiline scalar, safe value", input: "- multi\n  ${APP_SIMPLE} line\n- x", expected: "- multi\n  nginx:1.27 line\n- x"},
+		{name: "Plain, sequence", input: "- ${APP_ALIAS}", expected: `- "*ref"`},
+		{name: "Plain, anchor", input: "name: &app ${APP_COLON}", expected: `name: &app "a: b"`},
+		{name: "Plain, key", input: "${APP_COLON}: value", expected: `"a: b": value`},
+		{name: "Flow", input: "args: [${APP_SIMPLE}, ${APP_HASH}]", expected: `args: [nginx:1.27, "x #y"]`},
+		{name: "Single-quoted", input: "key: 'x ${APP_QUOTES}'", expected: `key: 'x it''s "q"'`},
+		{name: "Single-quoted, multiline", input: "key: 'it''s ${APP_LINES}'", expected: `key: "it's line1\nline2"`},
+		{name: "Single-quoted, multiline scalar", input: "a: 'multi\n  line ${APP_MULTI}'", expected: `a: "multi line l1\nl2"`},
+		{name: "Double-quoted", input: `key: "x ${APP_QUOTES}"`, expected: `key: "x it's \"q\""`},
+		{name: "Double-quoted, multiline", input: `key: "${APP_LINES}"`, expected: `key: "line1\nline2"`},
+		{name: "Block scalar", input: "key: |\n  ${APP_COLON} ${APP_QUOTES}", expected: "key: |\n  a: b it's \"q\""},
+		{name: "Comment", input: "# ${APP_COLON}", expected: "# a: b"},
+		{name: "Whole line", input: "spec:\n  ${APP_COLON}", expected: "spec:\n  a: b"},
+		{name: "Raw filter", input: "key: ${APP_COLON | raw}", expected: "key: a: b"},
+		{name: "Quote filter", input: "key: ${APP_COLON | quote}", expected: `key: "a: b"`},
+		{name: "Unresolved", input: "key: ${APP_UNSET:-a: b} $OTHER", expected: `key: "a: b $OTHER"`},
+		{name: "JSON string", filename: "cm.json", input: `{"key": "${APP_QUOTES}"}`, expected: `{"key": "it's \"q\""}`},
+		{name: "JSON value", filename: "cm.json", input: `{"replicas": ${APP_NUMBER}}`, expected: `{"replicas": 3}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			envsubst := NewEnvsubst([]string{"OTHER"}, []string{"APP_"}, false)
+			envsubst.SetFilename(test.filename)
+			result, err := envsubst.SubstituteEnvs(test.input)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result != test.expected {
+				t.Errorf("Expected '%s', got '%s'", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestReindent(t *testing.T) {
+	tests := []struct {
+		value       string
+		indentation string
+		expected    string
+	}{
+		{value: "a", indentation: "    ", expected: "a"},
+		{value: "a\nb", indentation: "", expected: "a\nb"},
+		{value: "a\nb", indentation: "  ", expected: "a\n  b"},
+		{value: "a\n\nb\n", indentation: "  ", expected: "a\n\n  b\n"},
+		{value: "a\r\nb\r\n\r\n", indentation: "\t ", expected: "a\r\n\t b\r\n\r\n"},
+	}
+
+	for _, test := range tests {
+		if result := reindent(test.value, test.indentation); result != test.expected {
+			t.Errorf("For %q, expected %q, got %q", test.value, test.expected, result)
+		}
+	}
+}
+
+func TestIndentationAt(t *testing.T) {
+	input := "a:\n\t- key: ${X}"
+	if result := indentationAt(input, strings.Index(input, "$")); result != "\t       " {
+		t.Errorf("Expected %q, got %q", "\t       ", result)
+	}
+}
+
+func TestSubstituteEnvs_MultilineIndentation(t *testing.T) {
+	os.Setenv("APP_CERT", "-----BEGIN-----\nabc\n\n-----END-----")
+	os.Setenv("APP_RESOURCES", "limits:\n  cpu: 1")
+	os.Setenv("APP_LEADING", " lead\nx")
+	defer os.Unsetenv("APP_CERT")
+	defer os.Unsetenv("APP_RESOURCES")
+	defer os.Unsetenv("APP_LEADING")
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "Block scalar",
+			input:    "tls.crt: |\n  ${APP_CERT}\n",
+			expected: "tls.crt: |\n  -----BEGIN-----\n  abc\n\n  -----END-----\n",
+		},
+		{
+			name:     "Block scalar, placeholder inside of a line",
+			input:    "conf: |-\n  cert = ${APP_CERT}\n  end\n",
+			expected: "conf: |-\n  cert = -----BEGIN-----\n         abc\n\n         -----END-----\n  end\n",
+		},
+		{
+			name:     "Folded block scalar",
+			input:    "tls.crt: >\n  ${APP_CERT}\n",
+			expected: "tls.crt: >\n  -----BEGIN-----\n\n  abc\n\n\n  -----END-----\n",
+		},
+		{
+			name:     "Folded block scalar, more-indented lines",
+			input:    "spec: >\n  ${APP_RESOURCES}\n",
+			expected: "spec: >\n  limits:\n    cpu: 1\n",
+		},
+		{
+			name:     "Block scalar, leading whitespace",
+			input:    "key: |-\n  ${APP_LEADING}\n",
+			expected: "key: |2-\n   lead\n  x\n",
+		},
+		{
+			name:     "Block scalar, leading whitespace with indentation indicator",
+			input:    "key: |4\n    ${APP_LEADING}\n",
+			expected: "key: |4\n     lead\n    x\n",
+		},
+		{
+			name:     "Block scalar, text after a line break",
+			input:    "key: |\n  ${APP_RESOURCES}\n  ${APP_LEADING}-end\n",
+			expected: "key: |\n  limits:\n    cpu: 1\n   lead\n  x-end\n",
+		},
+		{
+			name:     "Block scalar, raw",
+			input:    "tls.crt: |\n  ${APP_CERT | raw}\n",
+			expected: "tls.crt: |\n  -----BEGIN-----\nabc\n\n-----END-----\n",
+		},
+		{
+			name:     "Autoindent, whole line",
+			input:    "spec:\n  ${APP_RESOURCES | autoindent}\n",
+			expected: "spec:\n  limits:\n    cpu: 1\n",
+		},
+		{
+			name:     "Autoindent, sequence",
+			input:    "items:\n  - ${APP_RESOURCES | autoindent}\n",
+			expected: "items:\n  - limits:\n      cpu: 1\n",
+		},
+		{
+			name:     "Autoindent, after other filters",
+			input:    "spec:\n  ${APP_RESOURCES | upper | autoindent}\n",
+			expected: "spec:\n  LIMITS:\n    CPU: 1\n",
+		},
+		{
+			name:     "Plain scalar",
+			input:    "key: ${APP_RESOURCES}\n",
+			expected: "key: \"limits:\\n  cpu: 1\"\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			envsubst := NewEnvsubst([]string{}, []string{"APP_"}, false)
+			result, err := envsubst.SubstituteEnvs(test.input)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result != test.expected {
+				t.Errorf("Expected %q, got %q", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestSubstituteEnvs_BlockScalarValues(t *testing.T) {
+	values := []string{"l1\nl2", " lead\nx", "a\n\nb", "a\n  more\nb", "\tt\nx", "\nx", "a\n"}
+	templates := []string{
+		"key: |\n  ${APP_VALUE}\n",
+		"key: >\n  ${APP_VALUE}\n",
+		"top:\n  nested: >\n      ${APP_VALUE}\n",
+		"list:\n  - |\n    ${APP_VALUE}\n",
+		"list:\n- k: >\n    ${APP_VALUE}\n",
+	}
+
+	for _, template := range templates {
+		for _, value := range values {
+			envsubst := NewEnvsubst([]string{}, []string{"APP_"}, false, MapSource{"APP_VALUE": value})
+			result, err := envsubst.SubstituteEnvs(template)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			var node yaml.Node
+			if err := yaml.Unmarshal([]byte(result), &node); err != nil {
+				t.Errorf("For %q in %q, invalid YAML: %v\n%s", value, template, err, result)
+				continue
+			}
+			scalar := &node
+			for scalar.Kind != yaml.ScalarNode {
+				scalar = scalar.Content[len(scalar.Content)-1]
+			}
+			// clip chomping keeps a single trailing line break
+			expected := strings.TrimRight(value, "\n") + "\n"
+			if scalar.Value != expected {
+				t.Errorf("For %q in %q, expected %q, got %q", value, template, expected, scalar.Value)
+			}
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// maskedValue replaces values in explanations, unless they are shown with SetExplainValues
+const maskedValue = "******"
+
+// maxExplainedValue limits the length of a value in explanations
+const maxExplainedValue = 40
+
+// sensitiveWords mark variables, whose values are masked in explanations
+var sensitiveWords = []string{"PASSWORD", "PASSWD", "SECRET", "TOKEN", "KEY", "CREDENTIAL", "PRIVATE", "AUTH", "CERT"}
+
+// Explanation describes the outcome of a placeholder
+type Explanation struct {
+	// Position is the location of the placeholder: deployment.yaml:12:15
+	Position    string
+	Placeholder string
+	// Variable is the name of the variable, or the reference: file:certs/tls.crt
+	Variable string
+	// Rule describes the rule, that allowed the variable: var APP_IMAGE, prefix APP_, built-in
+	Rule string
+	// Source describes where the value came from: environment, env files, default, vault
+	Source string
+	// Value is the substituted value, it is masked, unless values are shown with SetExplainValues
+	Value string
+	// Unchanged is set for a placeholder, that was left untouched, Source holds the reason
+	Unchanged bool
+
+	pos position
+}
+
+// SetExplain enables recording of outcomes of placeholders, they are available with Explanations
+func (p *Envsubst) SetExplain(value bool) {
+	p.explain = value
+}
+
+// SetExplainValues shows substituted values in explanations, values of variables with sensitive names
+// and of references remain masked. Values are masked by default, since a value may come from a Secret,
+// an encrypted env file or a command, that are not known by names of variables.
+func (p *Envsubst) SetExplainValues(value bool) {
+	p.explainValues = value
+}
+
+// Explanations returns outcomes of placeholders of the last substituted document, ordered by their positions
+func (p *Envsubst) Explanations() []Explanation {
+	return p.explanations
+}
+
+// explainPlaceholder records the outcome of a placeholder t, named holds its plain name
+func (p *Envsubst) explainPlaceholder(t, named *token, value string, resolved bool, state *substitution) {
+	if !p.explain {
+		return
+	}
+	e := Explanation{
+		Position:    t.pos.String(),
+		Placeholder: t.raw,
+		Variable:    named.name,
+		pos:         t.pos,
+	}
+
+	switch {
+	case named.scheme != "" && p.resolvers[named.scheme] == nil:
+		e.Source = "unchanged: references of " + named.scheme + " are disabled"
+	case named.scheme != "" && resolved:
+		e.Rule = p.allowRule(named.name)
+		e.Source = named.scheme
+	case !resolved:
+		e.Rule = p.allowRule(named.name)
+		e.Source = "unchanged: not allowed"
+		if e.Rule != "" {
+			e.Source = "unchanged: undefined"
+		}
+		if rule := p.denyRule(named.name); rule != "" {
+			e.Source = "unchanged: denied by " + rule
+		}
+	default:
+		e.Rule = p.allowRule(named.name)
+		e.Source = p.valueSource(named, state)
+	}
+
+	e.Unchanged = !resolved
+	if resolved {
+		e.Value = p.explainValue(named, value)
+	}
+	p.explanations = append(p.explanations, e)
+}
+
+// valueSource describes where the value of a resolved placeholder came from
+func (p *Envsubst) valueSource(t *token, state *substitution) string {
+	origin := ""
+	original, ok := p.source.Lookup(t.name)
+	if ok {
+		origin = sourceName(p.source, t.name)
+	} else if original, ok = p.builtins[t.name]; ok {
+		origin = "built-in"
+	}
+
+	isSet := ok && (original != "" || !strings.HasPrefix(t.operator, ":"))
+	switch strings.TrimPrefix(t.operator, ":") {
+	case "-", "=", "?":
+		if !isSet {
+			return "default"
+		}
+	case "+":
+		if isSet {
+			return "alternate value"
+		}
+		return "unset"
+	}
+	if origin == "" {
+		if _, assigned := state.envMap[t.name]; assigned {
+			return "assigned in the document"
+		}
+	}
+	return origin
+}
+
+// explainValue returns a value for explanations, values are masked unless they are shown,
+// values of sensitive variables and of references are always masked, long values are truncated
+func (p *Envsubst) explainValue(t *token, value string) string {
+	if !p.explainValues || t.scheme != "" || isSensitive(t.name) {
+		return maskedValue
+	}
+	quoted := fmt.Sprintf("%q", value)
+	if len(quoted) > maxExplainedValue {
+		quoted = quoted[:maxExplainedValue-4] + `..."`
+	}
+	return quoted
+}
+
+// isSensitive checks whether a name of a variable contains one of sensitive words
+func isSensitive(name string) bool {
+	upper := strings.ToUpper(name)
+	for _, word := range sensitiveWords {
+		if strings.Contains(upper, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// sortExplanations orders explanations by positions of placeholders, a nested placeholder is recorded
+// before the one that contains it
+func (p *Envsubst) sortExplanations() {
+	sort.SliceStable(p.explanations, func(i, j int) bool {
+		a, b := p.explanations[i].pos, p.explanations[j].pos
+		if a.line != b.line {
+			return a.line < b.line
+		}
+		return a.column < b.column
+	})
+}
+
+// WriteExplanations writes explanations as a table
+func WriteExplanations(w io.Writer, explanations []Explanation) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "LOCATION\tPLACEHOLDER\tVARIABLE\tRULE\tSOURCE\tVALUE")
+	for _, e := range explanations {
+		rule := e.Rule
+		if rule == "" {
+			rule = "-"
+		}
+		value := e.Value
+		if e.Unchanged {
+			value = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", e.Position, e.Placeholder, e.Variable, rule, e.Source, value)
+	}
+	return tw.Flush()
+}
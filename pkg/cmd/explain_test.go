@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSubstituteEnvs_Explain(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "name.txt"), "my-app")
+	files, err := NewFileResolver(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source := ChainSource{
+		MapSource{"APP_NAME": "web", "APP_ENV": "prod", "APP_EMPTY": ""},
+		NamedSource{Name: "env files", VarSource: MapSource{"APP_DB_PASSWORD": "s3cr3t", "DEPLOY_HOST": "example.com"}},
+	}
+	envsubst := NewEnvsubst([]string{"DEPLOY_HOST"}, []string{"APP_", "file:", "exec:"}, false, source)
+	envsubst.SetFilename(filepath.Join(dir, "deployment.yaml"))
+	envsubst.SetResolver("file", files)
+	envsubst.AddBuiltins(map[string]string{"ENVSUBST_UUID": "1234"})
+	envsubst.SetDenied([]string{"APP_SECRET_KEY"}, nil)
+	envsubst.SetExplain(true)
+	envsubst.SetExplainValues(true)
+
+	input := strings.Join([]string{
+		"name: ${APP_NAME | upper}",
+		"host: $DEPLOY_HOST",
+		"password: ${APP_DB_PASSWORD}",
+		"level: ${APP_LEVEL:-info} ${APP_EMPTY:-empty} ${APP_ENV:+alt}",
+		"home: $HOME ${APP_MISSING}",
+		"image: ${APP_IMAGE_${APP_ENV}} ${file:name.txt} ${exec:version}",
+		"uid: ${ENVSUBST_UUID} ${APP_X:=x} ${APP_X}",
+		"denied: ${APP_SECRET_KEY}",
+	}, "\n")
+	if _, err := envsubst.SubstituteEnvs(input); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	type row struct{ position, variable, rule, source, value string }
+	expected := []row{
+		{"deployment.yaml:1:7", "APP_NAME", "prefix APP_", "source", `"WEB"`},
+		{"deployment.yaml:2:7", "DEPLOY_HOST", "var DEPLOY_HOST", "env files", `"example.com"`},
+		{"deployment.yaml:3:11", "APP_DB_PASSWORD", "prefix APP_", "env files", maskedValue},
+		{"deployment.yaml:4:8", "APP_LEVEL", "prefix APP_", "default", `"info"`},
+		{"deployment.yaml:4:27", "APP_EMPTY", "prefix APP_", "default", `"empty"`},
+		{"deployment.yaml:4:47", "APP_ENV", "prefix APP_", "alternate value", `"alt"`},
+		{"deployment.yaml:5:7", "HOME", "", "unchanged: not allowed", ""},
+		{"deployment.yaml:5:13", "APP_MISSING", "prefix APP_", "unchanged: undefined", ""},
+		{"deployment.yaml:6:8", "APP_IMAGE_prod", "prefix APP_", "unchanged: undefined", ""},
+		{"deployment.yaml:6:20", "APP_ENV", "prefix APP_", "source", `"prod"`},
+		{"deployment.yaml:6:32", "file:name.txt", "prefix file:", "file", maskedValue},
+		{"deployment.yaml:6:49", "exec:version", "", "unchanged: references of exec are disabled", ""},
+		{"deployment.yaml:7:6", "ENVSUBST_UUID", "built-in", "built-in", `"1234"`},
+		{"deployment.yaml:7:23", "APP_X", "prefix APP_", "default", `"x"`},
+		{"deployment.yaml:7:35", "APP_X", "prefix APP_", "assigned in the document", `"x"`},
+		{"deployment.yaml:8:9", "APP_SECRET_KEY", "", "unchanged: denied by var APP_SECRET_KEY", ""},
+	}
+
+	got := []row{}
+	for _, e := range envsubst.Explanations() {
+		position := strings.TrimPrefix(e.Position, dir+string(filepath.Separator))
+		got = append(got, row{position, e.Variable, e.Rule, e.Source, e.Value})
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected:\n%v\ngot:\n%v", expected, got)
+	}
+}
+
+func TestSubstituteEnvs_ExplainDisabled(t *testing.T) {
+	envsubst := NewEnvsubst([]string{}, []string{"APP_"}, false, MapSource{"APP_NAME": "web"})
+	if _, err := envsubst.SubstituteEnvs("name: ${APP_NAME}"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(envsubst.Explanations()) != 0 {
+		t.Errorf("Expected no explanations, got %v", envsubst.Explanations())
+	}
+}
+
+func TestSubstituteEnvs_ExplainMasked(t *testing.T) {
+	// values are masked by default, their sources are not known by names: Secrets, encrypted env files
+	source := NamedSource{Name: "manifests", VarSource: MapSource{"APP_DB_DSN": "postgres://app:s3cr3t@db/app"}}
+	envsubst := NewEnvsubst([]string{}, []string{"APP_"}, false, source)
+	envsubst.AddBuiltins(map[string]string{"ENVSUBST_UUID": "1234"})
+	envsubst.SetExplain(true)
+	if _, err := envsubst.SubstituteEnvs("dsn: ${APP_DB_DSN}\nuid: ${ENVSUBST_UUID}\nlevel: ${APP_LEVEL:-info}"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	explanations := envsubst.Explanations()
+	if len(explanations) != 3 {
+		t.Fatalf("Expected 3 explanations, got %v", explanations)
+	}
+	for _, e := range explanations {
+		if e.Value != maskedValue {
+			t.Errorf("Expected a masked value of %s, got '%s'", e.Variable, e.Value)
+		}
+	}
+}
+
+func TestExplainValue(t *testing.T) {
+	envsubst := NewEnvsubst([]string{}, []string{"APP_"}, false)
+	envsubst.SetExplainValues(true)
+
+	tests := []struct {
+		name     string
+		token    token
+		value    string
+		expected string
+	}{
+		{name: "Plain value", token: token{name: "APP_NAME"}, value: "web", expected: `"web"`},
+		{name: "Escaped value", token: token{name: "APP_GREETING"}, value: "a\nb", expected: `"a\nb"`},
+		{name: "Long value", token: token{name: "APP_TEXT"}, value: strings.Repeat("x", 50), expected: `"` + strings.Repeat("x", 35) + `..."`},
+		{name: "Sensitive name", token: token{name: "APP_API_Token"}, value: "abc", expected: maskedValue},
+		{name: "Vault reference", token: token{name: "vault:secret/data/app#user", scheme: "vault"}, value: "admin", expected: maskedValue},
+		{name: "Exec reference", token: token{name: "exec:version", scheme: "exec"}, value: "v1.2.3", expected: maskedValue},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if result := envsubst.explainValue(&test.token, test.value); result != test.expected {
+				t.Errorf("Expected '%s', got '%s'", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestWriteExplanations(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteExplanations(&buf, []Explanation{
+		{Position: "a.yaml:1:7", Placeholder: "${APP_NAME}", Variable: "APP_NAME", Rule: "prefix APP_", Source: "environment", Value: `"web"`},
+		{Position: "a.yaml:2:7", Placeholder: "$HOME", Variable: "HOME", Source: "unchanged: not allowed", Unchanged: true},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := "LOCATION    PLACEHOLDER  VARIABLE  RULE         SOURCE                  VALUE\n" +
+		"a.yaml:1:7  ${APP_NAME}  APP_NAME  prefix APP_  environment             \"web\"\n" +
+		"a.yaml:2:7  $HOME        HOME      -            unchanged: not allowed  -\n"
+	if buf.String() != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
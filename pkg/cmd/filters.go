@@ -0,0 +1,339 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FilterFunc transforms a value of a placeholder, args are the arguments of the filter
+type FilterFunc func(value string, args []string) (string, error)
+
+// filters holds the registry of filters, that may be used in placeholders: ${VAR | name args...}
+//
+//	b64enc, b64dec   base64 encoding and decoding, e.g. for Secret data fields
+//	quote, squote    double-quoted (JSON compatible) or single-quoted string
+//	upper, lower     change the case
+//	trim             remove leading and trailing whitespace
+//	trunc N          keep at most N characters
+//	replace OLD NEW  replace all occurrences of OLD with NEW
+//	indent N         indent every line with N spaces
+//	nindent N        same as indent, with a leading newline
+//	sha256           hex-encoded sha256 checksum
+//	urlquery         escape the value for a URL query
+//	raw              insert the value as is, without escaping for the context of the placeholder
+//	autoindent       same as raw, continuation lines are indented to the column of the placeholder
+//	dns1123          sanitize the value into a DNS-1123 label: lowercase alphanumerics separated by single dashes, at most 63 characters
+var filters = map[string]FilterFunc{
+	"b64enc":     filterB64enc,
+	"b64dec":     filterB64dec,
+	"quote":      filterQuote,
+	"squote":     filterSquote,
+	"upper":      filterUpper,
+	"lower":      filterLower,
+	"trim":       filterTrim,
+	"trunc":      filterTrunc,
+	"replace":    filterReplace,
+	"indent":     filterIndent,
+	"nindent":    filterNindent,
+	"sha256":     filterSha256,
+	"urlquery":   filterURLQuery,
+	"dns1123":    filterDNS1123,
+	"raw":        filterRaw,
+	"autoindent": filterRaw,
+}
+
+// formattingFilters produce a text, that is inserted as is, without escaping for the context of a placeholder
+var formattingFilters = map[string]bool{
+	"quote":      true,
+	"squote":     true,
+	"indent":     true,
+	"nindent":    true,
+	"raw":        true,
+	"autoindent": true,
+}
+
+// RegisterFilter adds a filter to the registry, an existing filter with the same name is replaced
+func RegisterFilter(name string, fn FilterFunc) {
+	filters[name] = fn
+}
+
+// FilterNames returns names of all registered filters
+func FilterNames() []string {
+	result := make([]string, 0, len(filters))
+	for name := range filters {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// filterCall is a filter with arguments in a placeholder: ${VAR | trunc 63}
+type filterCall struct {
+	name string
+	args []string
+}
+
+// isFormatted checks whether a pipeline contains a formatting filter
+func isFormatted(calls []filterCall) bool {
+	for _, call := range calls {
+		if formattingFilters[call.name] {
+			return true
+		}
+	}
+	return false
+}
+
+// hasFilter checks whether a pipeline contains a filter with the name
+func hasFilter(calls []filterCall, name string) bool {
+	for _, call := range calls {
+		if call.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// applyFilters passes the value through a pipeline of filters
+func applyFilters(value string, calls []filterCall) (string, error) {
+	for _, call := range calls {
+		fn, ok := filters[call.name]
+		if !ok {
+			return "", fmt.Errorf("unknown filter: %s", call.name)
+		}
+		result, err := fn(value, call.args)
+		if err != nil {
+			return "", fmt.Errorf("filter %s: %w", call.name, err)
+		}
+		value = result
+	}
+	return value, nil
+}
+
+// parseFilters parses a pipeline of filters: "b64enc | trunc 63", arguments may be double-quoted
+func parseFilters(pipeline string) ([]filterCall, error) {
+	calls := []filterCall{}
+	for _, part := range splitUnquoted(pipeline, '|') {
+		fields, err := splitFields(part)
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("empty filter")
+		}
+		calls = append(calls, filterCall{name: fields[0], args: fields[1:]})
+	}
+	return calls, nil
+}
+
+// splitUnquoted splits the text at separators outside of double quotes
+func splitUnquoted(text string, sep byte) []string {
+	result := []string{}
+	quoted := false
+	start := 0
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case '"':
+			quoted = !quoted
+		case sep:
+			if !quoted {
+				result = append(result, text[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(result, text[start:])
+}
+
+// splitFields splits the text at whitespace, double-quoted fields are unquoted
+func splitFields(text string) ([]string, error) {
+	result := []string{}
+	i := 0
+	for i < len(text) {
+		if text[i] == ' ' || text[i] == '\t' {
+			i++
+			continue
+		}
+
+		start := i
+		if text[i] != '"' {
+			for i < len(text) && text[i] != ' ' && text[i] != '\t' {
+				i++
+			}
+			result = append(result, text[start:i])
+			continue
+		}
+
+		// double-quoted field, with escape sequences
+		for i++; i < len(text) && text[i] != '"'; i++ {
+			if text[i] == '\\' {
+				i++
+			}
+		}
+		unquoted, err := strconv.Unquote(text[start:min(i+1, len(text))])
+		if err != nil {
+			return nil, fmt.Errorf("invalid quoted argument: %s", text[start:min(i+1, len(text))])
+		}
+		result = append(result, unquoted)
+		i++
+	}
+	return result, nil
+}
+
+// Filters
+
+func expectArgs(args []string, count int) error {
+	if len(args) != count {
+		return fmt.Errorf("expected %d argument(s), got %d", count, len(args))
+	}
+	return nil
+}
+
+func intArg(args []string) (int, error) {
+	if err := expectArgs(args, 1); err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("expected a non-negative number, got %q", args[0])
+	}
+	return n, nil
+}
+
+func filterB64enc(value string, args []string) (string, error) {
+	if err := expectArgs(args, 0); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString([]byte(value)), nil
+}
+
+func filterB64dec(value string, args []string) (string, error) {
+	if err := expectArgs(args, 0); err != nil {
+		return "", err
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil {
+		return "", err
+	}
+	return string(decoded), nil
+}
+
+func filterQuote(value string, args []string) (string, error) {
+	if err := expectArgs(args, 0); err != nil {
+		return "", err
+	}
+	quoted, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(quoted), nil
+}
+
+func filterSquote(value string, args []string) (string, error) {
+	if err := expectArgs(args, 0); err != nil {
+		return "", err
+	}
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'", nil
+}
+
+func filterUpper(value string, args []string) (string, error) {
+	if err := expectArgs(args, 0); err != nil {
+		return "", err
+	}
+	return strings.ToUpper(value), nil
+}
+
+func filterLower(value string, args []string) (string, error) {
+	if err := expectArgs(args, 0); err != nil {
+		return "", err
+	}
+	return strings.ToLower(value), nil
+}
+
+func filterTrim(value string, args []string) (string, error) {
+	if err := expectArgs(args, 0); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(value), nil
+}
+
+func filterTrunc(value string, args []string) (string, error) {
+	n, err := intArg(args)
+	if err != nil {
+		return "", err
+	}
+	runes := []rune(value)
+	if len(runes) > n {
+		return string(runes[:n]), nil
+	}
+	return value, nil
+}
+
+func filterReplace(value string, args []string) (string, error) {
+	if err := expectArgs(args, 2); err != nil {
+		return "", err
+	}
+	return strings.ReplaceAll(value, args[0], args[1]), nil
+}
+
+func filterIndent(value string, args []string) (string, error) {
+	n, err := intArg(args)
+	if err != nil {
+		return "", err
+	}
+	pad := strings.Repeat(" ", n)
+	return pad + strings.ReplaceAll(value, "\n", "\n"+pad), nil
+}
+
+func filterNindent(value string, args []string) (string, error) {
+	indented, err := filterIndent(value, args)
+	if err != nil {
+		return "", err
+	}
+	return "\n" + indented, nil
+}
+
+func filterSha256(value string, args []string) (string, error) {
+	if err := expectArgs(args, 0); err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func filterURLQuery(value string, args []string) (string, error) {
+	if err := expectArgs(args, 0); err != nil {
+		return "", err
+	}
+	return url.QueryEscape(value), nil
+}
+
+func filterRaw(value string, args []string) (string, error) {
+	if err := expectArgs(args, 0); err != nil {
+		return "", err
+	}
+	return value, nil
+}
+
+var dns1123InvalidChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+func filterDNS1123(value string, args []string) (string, error) {
+	if err := expectArgs(args, 0); err != nil {
+		return "", err
+	}
+	label := dns1123InvalidChars.ReplaceAllString(strings.ToLower(value), "-")
+	label = strings.Trim(label, "-")
+	if len(label) > 63 {
+		label = strings.TrimRight(label[:63], "-")
+	}
+	return label, nil
+}
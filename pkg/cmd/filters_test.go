@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApplyFilters(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		pipeline    string
+		expected    string
+		expectError bool
+	}{
+		{name: "b64enc", value: "s3cr3t", pipeline: "b64enc", expected: "czNjcjN0"},
+		{name: "b64dec", value: "czNjcjN0", pipeline: "b64dec", expected: "s3cr3t"},
+		{name: "b64dec invalid", value: "%%%", pipeline: "b64dec", expectError: true},
+		{name: "quote", value: "a \"b\": #c\n", pipeline: "quote", expected: `"a \"b\": #c\n"`},
+		{name: "squote", value: "it's", pipeline: "squote", expected: "'it''s'"},
+		{name: "upper", value: "abc", pipeline: "upper", expected: "ABC"},
+		{name: "lower", value: "ABC", pipeline: "lower", expected: "abc"},
+		{name: "trim", value: "  abc\n", pipeline: "trim", expected: "abc"},
+		{name: "trunc", value: "abcdef", pipeline: "trunc 3", expected: "abc"},
+		{name: "trunc longer", value: "abc", pipeline: "trunc 63", expected: "abc"},
+		{name: "trunc without argument", value: "abc", pipeline: "trunc", expectError: true},
+		{name: "trunc invalid argument", value: "abc", pipeline: "trunc x", expectError: true},
+		{name: "replace", value: "a.b.c", pipeline: `replace "." "-"`, expected: "a-b-c"},
+		{name: "replace with spaces", value: "a b", pipeline: `replace " " "_"`, expected: "a_b"},
+		{name: "indent", value: "line1\nline2", pipeline: "indent 2", expected: "  line1\n  line2"},
+		{name: "nindent", value: "line1\nline2", pipeline: "nindent 2", expected: "\n  line1\n  line2"},
+		{name: "sha256", value: "abc", pipeline: "sha256", expected: "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{name: "urlquery", value: "a b&c", pipeline: "urlquery", expected: "a+b%26c"},
+		{name: "dns1123", value: "feature/ABC_12--x.", pipeline: "dns1123", expected: "feature-abc-12-x"},
+		{name: "dns1123 long", value: strings.Repeat("ab-", 30), pipeline: "dns1123", expected: strings.TrimSuffix(strings.Repeat("ab-", 21), "-")},
+		{name: "pipeline", value: "Feature/ABC-12", pipeline: "dns1123 | trunc 7 | b64enc", expected: "ZmVhdHVyZQ=="},
+		{name: "unknown filter", value: "abc", pipeline: "unknown", expectError: true},
+		{name: "unexpected argument", value: "abc", pipeline: "b64enc 1", expectError: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			calls, err := parseFilters(test.pipeline)
+			if err != nil {
+				t.Fatalf("Unexpected parse error: %v", err)
+			}
+			result, err := applyFilters(test.value, calls)
+			if (err != nil) != test.expectError {
+				t.Fatalf("Unexpected error status: got %v, want error=%v", err, test.expectError)
+			}
+			if result != test.expected {
+				t.Errorf("Expected %q, got %q", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestParseFilters(t *testing.T) {
+	tests := []struct {
+		pipeline    string
+		expected    []filterCall
+		expectError bool
+	}{
+		{pipeline: " b64enc ", expected: []filterCall{{name: "b64enc", args: []string{}}}},
+		{pipeline: "dns1123|trunc 63", expected: []filterCall{{name: "dns1123", args: []string{}}, {name: "trunc", args: []string{"63"}}}},
+		{pipeline: `replace "|" "\""`, expected: []filterCall{{name: "replace", args: []string{"|", `"`}}}},
+		{pipeline: "b64enc | ", expectError: true},
+		{pipeline: `replace "abc`, expectError: true},
+	}
+
+	for _, test := range tests {
+		result, err := parseFilters(test.pipeline)
+		if (err != nil) != test.expectError {
+			t.Errorf("For %q, unexpected error status: %v", test.pipeline, err)
+			continue
+		}
+		if !test.expectError && !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("For %q, expected %v, got %v", test.pipeline, test.expected, result)
+		}
+	}
+}
+
+func TestRegisterFilter(t *testing.T) {
+	RegisterFilter("reverse", func(value string, _ []string) (string, error) {
+		runes := []rune(value)
+		for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+			runes[i], runes[j] = runes[j], runes[i]
+		}
+		return string(runes), nil
+	})
+	defer delete(filters, "reverse")
+
+	if !varInSlice("reverse", FilterNames()) {
+		t.Errorf("Expected registered filter in %v", FilterNames())
+	}
+
+	os.Setenv("APP_NAME", "abc")
+	defer os.Unsetenv("APP_NAME")
+
+	envsubst := NewEnvsubst([]string{}, []string{"APP_"}, true)
+	result, err := envsubst.SubstituteEnvs("${APP_NAME | reverse | upper}")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result != "CBA" {
+		t.Errorf("Expected 'CBA', got '%s'", result)
+	}
+}
+
+func TestSubstituteEnvs_Filters(t *testing.T) {
+	os.Setenv("APP_PASSWORD", "s3cr3t")
+	os.Setenv("APP_BRANCH", "feature/ABC-12")
+	os.Setenv("APP_CERT", "-----BEGIN-----\nMIIB\n-----END-----")
+	defer os.Unsetenv("APP_PASSWORD")
+	defer os.Unsetenv("APP_BRANCH")
+	defer os.Unsetenv("APP_CERT")
+
+	tests := []struct {
+		name        string
+		input       string
+		expected    string
+		expectError bool
+	}{
+		{name: "Encode", input: "password: ${APP_PASSWORD | b64enc}", expected: "password: czNjcjN0"},
+		{name: "No spaces", input: "password: ${APP_PASSWORD|b64enc}", expected: "password: czNjcjN0"},
+		{name: "Pipeline", input: "app: ${APP_BRANCH | dns1123 | trunc 63}", expected: "app: feature-abc-12"},
+		{name: "Indent", input: "tls.crt: |\n${APP_CERT | indent 2}", expected: "tls.crt: |\n  -----BEGIN-----\n  MIIB\n  -----END-----"},
+		{name: "Operator", input: "${APP_UNSET:-main | quote}", expected: `"main"`},
+		{name: "String manipulation", input: "${APP_BRANCH##*/ | lower}", expected: "abc-12"},
+		{name: "Not in filter", input: "${OTHER | b64enc}", expected: "${OTHER | b64enc}"},
+		{name: "Unresolved in strict mode", input: "${APP_UNSET | b64enc}", expectError: true},
+		{name: "Unknown filter", input: "${APP_PASSWORD | unknown}", expectError: true},
+		{name: "Empty filter", input: "${APP_PASSWORD | }", expectError: true},
+		{name: "Unterminated", input: "${APP_PASSWORD | b64enc", expectError: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			envsubst := NewEnvsubst([]string{}, []string{"APP_"}, true)
+			result, err := envsubst.SubstituteEnvs(test.input)
+			if (err != nil) != test.expectError {
+				t.Fatalf("Unexpected error status: got %v, want error=%v", err, test.expectError)
+			}
+			if result != test.expected {
+				t.Errorf("Expected '%s', got '%s'", test.expected, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,660 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1" //nolint:gosec // object names of git repositories
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Git metadata is read from the .git directory of the repository of a manifest, git is not executed:
+// HEAD, loose and packed refs, loose tag objects, and the index for the dirty check.
+// Line endings of files are normalized for the dirty check, when core.autocrlf or text attributes are set.
+// Clean filters cannot be applied without git, so the dirty check runs git status, when they are used.
+
+// maxRefDepth limits chains of symbolic refs and of tags of tags
+const maxRefDepth = 10
+
+// GitInfo holds metadata of the checked out commit of a repository
+type GitInfo struct {
+	SHA string
+	// Branch is empty for a detached HEAD
+	Branch string
+	// Tag is the first tag of the commit in alphabetical order, it is empty if there are no tags
+	Tag string
+	// Dirty is set when tracked files of the working tree differ from the index
+	Dirty bool
+}
+
+// Vars returns built-in variables of the metadata
+func (g *GitInfo) Vars() map[string]string {
+	short := g.SHA
+	if len(short) > 7 {
+		short = short[:7]
+	}
+	return map[string]string{
+		"ENVSUBST_GIT_SHA":       g.SHA,
+		"ENVSUBST_GIT_SHORT_SHA": short,
+		"ENVSUBST_GIT_BRANCH":    g.Branch,
+		"ENVSUBST_GIT_TAG":       g.Tag,
+		"ENVSUBST_GIT_DIRTY":     strconv.FormatBool(g.Dirty),
+	}
+}
+
+// GitReader reads metadata of repositories of manifests, once per repository
+type GitReader struct {
+	mu    sync.Mutex
+	infos map[string]*GitInfo
+}
+
+func NewGitReader() *GitReader {
+	return &GitReader{infos: make(map[string]*GitInfo)}
+}
+
+// Vars returns built-in variables of the repository of a manifest, the repository of the current
+// directory is used for remote manifests and stdin
+func (r *GitReader) Vars(filename string) (map[string]string, error) {
+	dir := filepath.Dir(filename)
+	if IsURL(filename) {
+		dir = "."
+	}
+	repo, err := findGitRepository(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	info, ok := r.infos[repo.worktree]
+	if !ok {
+		info, err = repo.info()
+		if err != nil {
+			return nil, fmt.Errorf("git repository %s: %w", repo.worktree, err)
+		}
+		r.infos[repo.worktree] = info
+	}
+	return info.Vars(), nil
+}
+
+// gitRepository holds locations of a repository, a linked worktree has its own git directory,
+// and shares refs and objects of the common one
+type gitRepository struct {
+	worktree  string
+	gitDir    string
+	commonDir string
+	hashLen   int
+}
+
+// findGitRepository looks for a repository in the directory and its ancestors
+func findGitRepository(dir string) (*gitRepository, error) {
+	start, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	for dir = start; ; {
+		dotGit := filepath.Join(dir, ".git")
+		if info, err := os.Stat(dotGit); err == nil {
+			return openGitRepository(dir, dotGit, info.IsDir())
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, fmt.Errorf("no git repository found for %s", start)
+		}
+		dir = parent
+	}
+}
+
+func openGitRepository(worktree, dotGit string, isDir bool) (*gitRepository, error) {
+	repo := &gitRepository{worktree: worktree, gitDir: dotGit, hashLen: sha1.Size}
+
+	// linked worktrees and submodules: .git is a file with a path of the git directory
+	if !isDir {
+		content, err := os.ReadFile(dotGit)
+		if err != nil {
+			return nil, err
+		}
+		line := strings.TrimSpace(string(content))
+		if !strings.HasPrefix(line, "gitdir:") {
+			return nil, fmt.Errorf("%s: expected a gitdir line", dotGit)
+		}
+		repo.gitDir = resolveGitPath(worktree, strings.TrimSpace(strings.TrimPrefix(line, "gitdir:")))
+	}
+
+	repo.commonDir = repo.gitDir
+	if content, err := os.ReadFile(filepath.Join(repo.gitDir, "commondir")); err == nil {
+		repo.commonDir = resolveGitPath(repo.gitDir, strings.TrimSpace(string(content)))
+	}
+
+	if config, err := os.ReadFile(filepath.Join(repo.commonDir, "config")); err == nil {
+		for _, line := range strings.Split(string(config), "\n") {
+			if strings.ReplaceAll(strings.ToLower(line), " ", "") == "objectformat=sha256" {
+				repo.hashLen = sha256.Size
+			}
+		}
+	}
+	return repo, nil
+}
+
+func resolveGitPath(base, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(base, path)
+}
+
+// info reads metadata of the checked out commit
+func (r *gitRepository) info() (*GitInfo, error) {
+	content, err := os.ReadFile(filepath.Join(r.gitDir, "HEAD"))
+	if err != nil {
+		return nil, err
+	}
+
+	info := &GitInfo{SHA: strings.TrimSpace(string(content))}
+	if ref, ok := strings.CutPrefix(info.SHA, "ref: "); ok {
+		info.Branch = strings.TrimPrefix(ref, "refs/heads/")
+		sha, ok, err := r.resolveRef(ref)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("branch %s has no commits", info.Branch)
+		}
+		info.SHA = sha
+	}
+	if !r.isObjectName(info.SHA) {
+		return nil, fmt.Errorf("invalid HEAD %q", info.SHA)
+	}
+
+	tags, err := r.tags()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(tags))
+	for name := range tags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if tags[name] == info.SHA {
+			info.Tag = name
+			break
+		}
+	}
+
+	info.Dirty, err = r.dirty()
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// resolveRef returns the object name of a ref, following symbolic refs
+func (r *gitRepository) resolveRef(ref string) (string, bool, error) {
+	for depth := 0; depth < maxRefDepth; depth++ {
+		content, err := os.ReadFile(filepath.Join(r.commonDir, filepath.FromSlash(ref)))
+		if errors.Is(err, fs.ErrNotExist) {
+			packed, err := r.packedRefs()
+			if err != nil {
+				return "", false, err
+			}
+			sha, ok := packed[ref]
+			return sha, ok, nil
+		}
+		if err != nil {
+			return "", false, err
+		}
+		value := strings.TrimSpace(string(content))
+		target, symbolic := strings.CutPrefix(value, "ref: ")
+		if !symbolic {
+			return value, true, nil
+		}
+		ref = target
+	}
+	return "", false, fmt.Errorf("too many levels of symbolic refs: %s", ref)
+}
+
+// packedRefs returns object names of packed refs, and peeled object names of packed annotated tags
+// under the name of the tag with the ^{} suffix
+func (r *gitRepository) packedRefs() (map[string]string, error) {
+	refs := make(map[string]string)
+	f, err := os.Open(filepath.Join(r.commonDir, "packed-refs"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return refs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	last := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, "^"):
+			if last != "" {
+				refs[last+"^{}"] = line[1:]
+			}
+		default:
+			if sha, name, ok := strings.Cut(line, " "); ok {
+				refs[name] = sha
+				last = name
+			}
+		}
+	}
+	return refs, scanner.Err()
+}
+
+// tags returns names of tags and object names of commits they point to
+func (r *gitRepository) tags() (map[string]string, error) {
+	packed, err := r.packedRefs()
+	if err != nil {
+		return nil, err
+	}
+	tags := make(map[string]string)
+	for ref, sha := range packed {
+		name, ok := strings.CutPrefix(ref, "refs/tags/")
+		if !ok || strings.HasSuffix(name, "^{}") {
+			continue
+		}
+		if peeled, ok := packed[ref+"^{}"]; ok {
+			sha = peeled
+		}
+		tags[name] = sha
+	}
+
+	// loose tags take precedence over packed ones
+	tagsDir := filepath.Join(r.commonDir, "refs", "tags")
+	err = filepath.WalkDir(tagsDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(tagsDir, path)
+		if err != nil {
+			return err
+		}
+		tags[filepath.ToSlash(rel)] = r.peelTag(strings.TrimSpace(string(content)))
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// peelTag returns the object name of a commit of an annotated tag, that is stored as a loose object.
+// Other objects, including packed ones, are returned as is.
+func (r *gitRepository) peelTag(sha string) string {
+	for depth := 0; depth < maxRefDepth && r.isObjectName(sha); depth++ {
+		content, err := r.readLooseObject(sha)
+		if err != nil || !bytes.HasPrefix(content, []byte("tag ")) {
+			return sha
+		}
+		body := content[bytes.IndexByte(content, 0)+1:]
+		target, ok := bytes.CutPrefix(body, []byte("object "))
+		if !ok {
+			return sha
+		}
+		if end := bytes.IndexByte(target, '\n'); end >= 0 {
+			target = target[:end]
+		}
+		sha = string(target)
+	}
+	return sha
+}
+
+// readLooseObject returns the content of a loose object, with its header
+func (r *gitRepository) readLooseObject(sha string) ([]byte, error) {
+	f, err := os.Open(filepath.Join(r.commonDir, "objects", sha[:2], sha[2:]))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	z, err := zlib.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer z.Close()
+	return io.ReadAll(z)
+}
+
+func (r *gitRepository) isObjectName(sha string) bool {
+	decoded, err := hex.DecodeString(sha)
+	return err == nil && len(decoded) == r.hashLen
+}
+
+func (r *gitRepository) newHash() hash.Hash {
+	if r.hashLen == sha256.Size {
+		return sha256.New()
+	}
+	return sha1.New() //nolint:gosec // object names of git repositories
+}
+
+// indexEntry holds fields of an entry of the index, that are used by the dirty check
+type indexEntry struct {
+	path      string
+	mode      uint32
+	size      uint32
+	mtimeSec  uint32
+	mtimeNsec uint32
+	sha       string
+	// skip is set for entries, that are not checked out: conflicts are reported separately,
+	// gitlinks of submodules, assume-unchanged and skip-worktree entries
+	skip     bool
+	conflict bool
+}
+
+// file modes of the index
+const (
+	indexModeType    = 0o170000
+	indexModeSymlink = 0o120000
+	indexModeGitlink = 0o160000
+)
+
+// gitConversion holds settings, that change the content of files added to the index
+type gitConversion struct {
+	// crlf is set when line endings are normalized: core.autocrlf, text and eol attributes
+	crlf bool
+	// filtered is set when filter or ident attributes are used
+	filtered bool
+}
+
+// dirty checks whether tracked files of the working tree differ from the index
+func (r *gitRepository) dirty() (bool, error) {
+	entries, err := r.readIndex()
+	if err != nil {
+		return false, err
+	}
+	conversion := r.conversion(entries)
+	if conversion.filtered {
+		return r.statusDirty()
+	}
+	for i := range entries {
+		if entries[i].conflict {
+			return true, nil
+		}
+		if entries[i].skip {
+			continue
+		}
+		changed, err := r.changed(&entries[i], conversion)
+		if err != nil || changed {
+			return changed, err
+		}
+	}
+	return false, nil
+}
+
+// statusDirty runs git status, without refreshing the index, to check whether tracked files were changed
+func (r *gitRepository) statusDirty() (bool, error) {
+	cmd := exec.Command("git", "--no-optional-locks", "status", "--porcelain", "--untracked-files=no")
+	cmd.Dir = r.worktree
+	output, err := cmd.Output()
+	if err != nil {
+		return false, fmt.Errorf("git status is required for clean filters: %w", err)
+	}
+	return len(bytes.TrimSpace(output)) > 0, nil
+}
+
+// conversion reads core.autocrlf of the repository, global and system configs, and attributes of
+// the repository, of tracked .gitattributes files, and the global attributes file
+func (r *gitRepository) conversion(entries []indexEntry) gitConversion {
+	var conversion gitConversion
+
+	home, _ := os.UserHomeDir()
+	xdg := os.Getenv("XDG_CONFIG_HOME")
+	if xdg == "" && home != "" {
+		xdg = filepath.Join(home, ".config")
+	}
+	configs := []string{filepath.Join(r.commonDir, "config"), os.Getenv("GIT_CONFIG_GLOBAL"), "/etc/gitconfig"}
+	attributes := []string{filepath.Join(r.commonDir, "info", "attributes")}
+	if home != "" {
+		configs = append(configs, filepath.Join(home, ".gitconfig"))
+	}
+	if xdg != "" {
+		configs = append(configs, filepath.Join(xdg, "git", "config"))
+		attributes = append(attributes, filepath.Join(xdg, "git", "attributes"))
+	}
+	for _, config := range configs {
+		autocrlf, attributesFile := readCoreConfig(config)
+		if autocrlf == "true" || autocrlf == "input" {
+			conversion.crlf = true
+		}
+		if attributesFile != "" {
+			if rest, ok := strings.CutPrefix(attributesFile, "~/"); ok {
+				attributesFile = filepath.Join(home, rest)
+			}
+			attributes = append(attributes, attributesFile)
+		}
+	}
+	for i := range entries {
+		if entries[i].path == ".gitattributes" || strings.HasSuffix(entries[i].path, "/.gitattributes") {
+			attributes = append(attributes, filepath.Join(r.worktree, filepath.FromSlash(entries[i].path)))
+		}
+	}
+
+	for _, filename := range attributes {
+		content, err := os.ReadFile(filename)
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(content), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+				continue
+			}
+			for _, attribute := range fields[1:] {
+				switch {
+				case attribute == "text", strings.HasPrefix(attribute, "text="), strings.HasPrefix(attribute, "eol="), attribute == "crlf":
+					conversion.crlf = true
+				case strings.HasPrefix(attribute, "filter="), attribute == "ident":
+					conversion.filtered = true
+				}
+			}
+		}
+	}
+	return conversion
+}
+
+// readCoreConfig returns core.autocrlf and core.attributesFile of a config file, that may not exist
+func readCoreConfig(filename string) (autocrlf, attributesFile string) {
+	if filename == "" {
+		return "", ""
+	}
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return "", ""
+	}
+	section := ""
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			section = strings.ToLower(strings.Trim(line, "[] \t"))
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || section != "core" {
+			continue
+		}
+		value = strings.Trim(strings.TrimSpace(value), `"`)
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "autocrlf":
+			autocrlf = strings.ToLower(value)
+		case "attributesfile":
+			attributesFile = value
+		}
+	}
+	return autocrlf, attributesFile
+}
+
+// changed compares a file of the working tree with its entry of the index, the content is hashed
+// only when the size is the same, and the modification time is not. Line endings are normalized,
+// when the content differs, and conversion of line endings is configured.
+func (r *gitRepository) changed(e *indexEntry, conversion gitConversion) (bool, error) {
+	path := filepath.Join(r.worktree, filepath.FromSlash(e.path))
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	// the index holds sizes and times truncated to 32 bits, the size of a file with converted
+	// line endings differs from the size of its blob
+	if info.Size()&0xffffffff != int64(e.size) && !conversion.crlf {
+		return true, nil
+	}
+	mtime := info.ModTime()
+	if mtime.Unix()&0xffffffff == int64(e.mtimeSec) && int64(mtime.Nanosecond()) == int64(e.mtimeNsec) {
+		return false, nil
+	}
+
+	var content []byte
+	if e.mode&indexModeType == indexModeSymlink {
+		target, err := os.Readlink(path)
+		if err != nil {
+			return false, err
+		}
+		content = []byte(target)
+	} else if content, err = os.ReadFile(path); err != nil {
+		return false, err
+	}
+
+	if r.blobName(content) == e.sha {
+		return false, nil
+	}
+	if conversion.crlf && bytes.Contains(content, []byte("\r\n")) {
+		return r.blobName(bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))) != e.sha, nil
+	}
+	return true, nil
+}
+
+// blobName returns the object name of a blob with the content
+func (r *gitRepository) blobName(content []byte) string {
+	h := r.newHash()
+	fmt.Fprintf(h, "blob %d\x00", len(content))
+	h.Write(content)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// readIndex parses entries of the index, versions 2 to 4 are supported
+func (r *gitRepository) readIndex() ([]indexEntry, error) {
+	data, err := os.ReadFile(filepath.Join(r.gitDir, "index"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 12 || string(data[:4]) != "DIRC" {
+		return nil, errors.New("invalid index")
+	}
+	version := binary.BigEndian.Uint32(data[4:8])
+	if version < 2 || version > 4 {
+		return nil, fmt.Errorf("unsupported index version %d", version)
+	}
+	count := binary.BigEndian.Uint32(data[8:12])
+
+	entries := make([]indexEntry, 0, count)
+	offset := 12
+	previous := ""
+	for i := uint32(0); i < count; i++ {
+		e, end, err := r.readIndexEntry(data, offset, version, previous)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+		offset, previous = end, e.path
+	}
+	return entries, nil
+}
+
+// readIndexEntry parses an entry of the index at the offset, it returns the offset after the entry
+func (r *gitRepository) readIndexEntry(data []byte, offset int, version uint32, previous string) (indexEntry, int, error) {
+	errTruncated := errors.New("truncated index")
+	fixed := 40 + r.hashLen + 2
+	if offset+fixed > len(data) {
+		return indexEntry{}, 0, errTruncated
+	}
+	fields := data[offset:]
+	e := indexEntry{
+		mtimeSec:  binary.BigEndian.Uint32(fields[8:]),
+		mtimeNsec: binary.BigEndian.Uint32(fields[12:]),
+		mode:      binary.BigEndian.Uint32(fields[24:]),
+		size:      binary.BigEndian.Uint32(fields[36:]),
+		sha:       hex.EncodeToString(fields[40 : 40+r.hashLen]),
+	}
+	flags := binary.BigEndian.Uint16(fields[40+r.hashLen:])
+	pos := offset + fixed
+
+	// extended flags of version 3 and later: skip-worktree, intent-to-add
+	skipWorktree := false
+	if flags&0x4000 != 0 {
+		if pos+2 > len(data) {
+			return indexEntry{}, 0, errTruncated
+		}
+		skipWorktree = binary.BigEndian.Uint16(data[pos:])&0x4000 != 0
+		pos += 2
+	}
+	assumeValid := flags&0x8000 != 0
+	e.conflict = (flags>>12)&0x3 != 0
+	e.skip = assumeValid || skipWorktree || e.mode&indexModeType == indexModeGitlink
+
+	// version 4 compresses paths: the number of bytes to remove from the previous path, and a suffix
+	prefix := ""
+	if version == 4 {
+		strip, n := readIndexVarint(data[pos:])
+		if n == 0 || strip > len(previous) {
+			return indexEntry{}, 0, errTruncated
+		}
+		prefix = previous[:len(previous)-strip]
+		pos += n
+	}
+	nameEnd := bytes.IndexByte(data[pos:], 0)
+	if nameEnd < 0 {
+		return indexEntry{}, 0, errTruncated
+	}
+	e.path = prefix + string(data[pos:pos+nameEnd])
+	pos += nameEnd + 1
+
+	// entries of versions 2 and 3 are padded with NUL bytes to a multiple of 8 bytes
+	if version < 4 {
+		pos = offset + (pos-offset+7)/8*8
+	}
+	return e, pos, nil
+}
+
+// readIndexVarint reads a variable-length integer of paths of index version 4,
+// it returns the value and the number of bytes read, or zero bytes for a truncated integer
+func readIndexVarint(data []byte) (value, n int) {
+	if len(data) == 0 {
+		return 0, 0
+	}
+	value = int(data[0] & 0x7f)
+	for n = 1; data[n-1]&0x80 != 0; n++ {
+		if n >= len(data) {
+			return 0, 0
+		}
+		value = ((value + 1) << 7) | int(data[n]&0x7f)
+	}
+	return value, n
+}
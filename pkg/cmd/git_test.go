@@ -0,0 +1,314 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testSHA1 = "1111111111111111111111111111111111111111"
+	testSHA2 = "2222222222222222222222222222222222222222"
+	testSHA3 = "3333333333333333333333333333333333333333"
+)
+
+func TestGitReader_Refs(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		expected map[string]string
+	}{
+		{
+			name: "Loose branch and lightweight tag",
+			files: map[string]string{
+				".git/HEAD":               "ref: refs/heads/main\n",
+				".git/refs/heads/main":    testSHA1 + "\n",
+				".git/refs/tags/v1.0.0":   testSHA1 + "\n",
+				".git/refs/tags/v0.9.0":   testSHA2 + "\n",
+				".git/refs/tags/rc/x":     testSHA3 + "\n",
+				"manifests/manifest.yaml": "",
+			},
+			expected: map[string]string{
+				"ENVSUBST_GIT_SHA":       testSHA1,
+				"ENVSUBST_GIT_SHORT_SHA": "1111111",
+				"ENVSUBST_GIT_BRANCH":    "main",
+				"ENVSUBST_GIT_TAG":       "v1.0.0",
+				"ENVSUBST_GIT_DIRTY":     "false",
+			},
+		},
+		{
+			name: "Packed refs and peeled annotated tags",
+			files: map[string]string{
+				".git/HEAD": "ref: refs/heads/feature/x\n",
+				".git/packed-refs": "# pack-refs with: peeled fully-peeled sorted\n" +
+					testSHA1 + " refs/heads/feature/x\n" +
+					testSHA2 + " refs/tags/v2.0.0\n" +
+					"^" + testSHA1 + "\n" +
+					testSHA3 + " refs/tags/v1.0.0\n",
+				"manifests/manifest.yaml": "",
+			},
+			expected: map[string]string{
+				"ENVSUBST_GIT_SHA":       testSHA1,
+				"ENVSUBST_GIT_SHORT_SHA": "1111111",
+				"ENVSUBST_GIT_BRANCH":    "feature/x",
+				"ENVSUBST_GIT_TAG":       "v2.0.0",
+				"ENVSUBST_GIT_DIRTY":     "false",
+			},
+		},
+		{
+			name: "Detached HEAD of a linked worktree",
+			files: map[string]string{
+				"main/.git/refs/tags/v1.0.0":       testSHA2 + "\n",
+				"main/.git/worktrees/wt/HEAD":      testSHA2 + "\n",
+				"main/.git/worktrees/wt/commondir": "../..\n",
+				"wt/.git":                          "gitdir: ../main/.git/worktrees/wt\n",
+				"wt/manifests/manifest.yaml":       "",
+			},
+			expected: map[string]string{
+				"ENVSUBST_GIT_SHA":       testSHA2,
+				"ENVSUBST_GIT_SHORT_SHA": "2222222",
+				"ENVSUBST_GIT_BRANCH":    "",
+				"ENVSUBST_GIT_TAG":       "v1.0.0",
+				"ENVSUBST_GIT_DIRTY":     "false",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			manifest := ""
+			for name, content := range test.files {
+				writeTestFile(t, filepath.Join(dir, name), content)
+				if strings.HasSuffix(name, "manifest.yaml") {
+					manifest = filepath.Join(dir, name)
+				}
+			}
+
+			vars, err := NewGitReader().Vars(manifest)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(vars, test.expected) {
+				t.Errorf("Expected %v, got %v", test.expected, vars)
+			}
+		})
+	}
+}
+
+func TestGitReader_Errors(t *testing.T) {
+	tests := []struct {
+		name          string
+		files         map[string]string
+		expectedError string
+	}{
+		{
+			name:          "No repository",
+			files:         map[string]string{"manifest.yaml": ""},
+			expectedError: "no git repository found for",
+		},
+		{
+			name:          "Branch without commits",
+			files:         map[string]string{".git/HEAD": "ref: refs/heads/main\n", "manifest.yaml": ""},
+			expectedError: "branch main has no commits",
+		},
+		{
+			name:          "Invalid HEAD",
+			files:         map[string]string{".git/HEAD": "garbage\n", "manifest.yaml": ""},
+			expectedError: `invalid HEAD "garbage"`,
+		},
+		{
+			name:          "Invalid index",
+			files:         map[string]string{".git/HEAD": testSHA1 + "\n", ".git/index": "garbage", "manifest.yaml": ""},
+			expectedError: "invalid index",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range test.files {
+				writeTestFile(t, filepath.Join(dir, name), content)
+			}
+			_, err := NewGitReader().Vars(filepath.Join(dir, "manifest.yaml"))
+			if err == nil || !strings.Contains(err.Error(), test.expectedError) {
+				t.Errorf("Expected error containing '%s', got '%v'", test.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestGitReader_Repository(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	runGit := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, output)
+		}
+		return strings.TrimSpace(string(output))
+	}
+
+	runGit("init", "-q", "-b", "main")
+	writeTestFile(t, filepath.Join(dir, "manifests", "deployment.yaml"), "kind: Deployment\n")
+	writeTestFile(t, filepath.Join(dir, "README.md"), "readme\n")
+	if err := os.Symlink("README.md", filepath.Join(dir, "link.md")); err != nil {
+		t.Fatal(err)
+	}
+	runGit("add", ".")
+	runGit("commit", "-q", "-m", "initial")
+	runGit("tag", "-a", "v1.0.0", "-m", "release")
+	sha := runGit("rev-parse", "HEAD")
+	manifest := filepath.Join(dir, "manifests", "deployment.yaml")
+
+	expected := map[string]string{
+		"ENVSUBST_GIT_SHA":       sha,
+		"ENVSUBST_GIT_SHORT_SHA": sha[:7],
+		"ENVSUBST_GIT_BRANCH":    "main",
+		"ENVSUBST_GIT_TAG":       "v1.0.0",
+		"ENVSUBST_GIT_DIRTY":     "false",
+	}
+	for _, version := range []string{"2", "3", "4"} {
+		runGit("update-index", "--index-version", version)
+		vars, err := NewGitReader().Vars(manifest)
+		if err != nil {
+			t.Fatalf("Unexpected error for index version %s: %v", version, err)
+		}
+		if !reflect.DeepEqual(vars, expected) {
+			t.Errorf("Expected %v for index version %s, got %v", expected, version, vars)
+		}
+	}
+
+	// untracked files do not make the working tree dirty, modified tracked files do
+	writeTestFile(t, filepath.Join(dir, "untracked.txt"), "new\n")
+	if vars, _ := NewGitReader().Vars(manifest); vars["ENVSUBST_GIT_DIRTY"] != "false" {
+		t.Errorf("Expected a clean working tree with an untracked file, got %v", vars)
+	}
+	writeTestFile(t, filepath.Join(dir, "README.md"), "README\n")
+	if vars, _ := NewGitReader().Vars(manifest); vars["ENVSUBST_GIT_DIRTY"] != "true" {
+		t.Errorf("Expected a dirty working tree with a modified file, got %v", vars)
+	}
+	writeTestFile(t, filepath.Join(dir, "README.md"), "readme\n")
+	if err := os.Remove(filepath.Join(dir, "manifests", "deployment.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	if vars, _ := NewGitReader().Vars(filepath.Join(dir, "manifests", "other.yaml")); vars["ENVSUBST_GIT_DIRTY"] != "true" {
+		t.Errorf("Expected a dirty working tree with a deleted file, got %v", vars)
+	}
+
+	// metadata is read once per repository
+	reader := NewGitReader()
+	if _, err := reader.Vars(filepath.Join(dir, "README.md")); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	runGit("checkout", "-q", "--detach")
+	if vars, _ := reader.Vars(manifest); vars["ENVSUBST_GIT_BRANCH"] != "main" {
+		t.Errorf("Expected cached metadata, got %v", vars)
+	}
+}
+
+func TestGitReader_Conversion(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+
+	tests := []struct {
+		name   string
+		config []string
+		files  map[string]string
+	}{
+		{
+			name:   "Autocrlf",
+			config: []string{"core.autocrlf", "true"},
+			files:  map[string]string{"deployment.yaml": "kind: Deployment\r\nmetadata: {}\r\n"},
+		},
+		{
+			name: "Text attribute",
+			files: map[string]string{
+				".gitattributes":  "*.yaml text eol=crlf\n",
+				"deployment.yaml": "kind: Deployment\r\nmetadata: {}\r\n",
+			},
+		},
+		{
+			name:   "Clean filter",
+			config: []string{"filter.upper.clean", "tr a-z A-Z"},
+			files: map[string]string{
+				".gitattributes":  "*.txt filter=upper\n",
+				"deployment.yaml": "kind: Deployment\n",
+				"notes.txt":       "lowercase\n",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			runGit := func(args ...string) {
+				t.Helper()
+				cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+				if output, err := cmd.CombinedOutput(); err != nil {
+					t.Fatalf("git %v: %v: %s", args, err, output)
+				}
+			}
+			runGit("init", "-q", "-b", "main")
+			if test.config != nil {
+				runGit(append([]string{"config"}, test.config...)...)
+			}
+			for name, content := range test.files {
+				writeTestFile(t, filepath.Join(dir, name), content)
+			}
+			runGit("add", ".")
+			runGit("commit", "-q", "-m", "initial")
+			manifest := filepath.Join(dir, "deployment.yaml")
+
+			// a touched file is not dirty, git status is clean as well
+			future := time.Now().Add(time.Hour)
+			for name := range test.files {
+				if err := os.Chtimes(filepath.Join(dir, name), future, future); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if vars, err := NewGitReader().Vars(manifest); err != nil || vars["ENVSUBST_GIT_DIRTY"] != "false" {
+				t.Errorf("Expected a clean working tree after touching files, got %v, %v", vars, err)
+			}
+
+			writeTestFile(t, manifest, "kind: ConfigMap\r\n")
+			if vars, err := NewGitReader().Vars(manifest); err != nil || vars["ENVSUBST_GIT_DIRTY"] != "true" {
+				t.Errorf("Expected a dirty working tree with a modified file, got %v, %v", vars, err)
+			}
+		})
+	}
+}
+
+func TestReadIndexVarint(t *testing.T) {
+	tests := []struct {
+		data          []byte
+		expectedValue int
+		expectedN     int
+	}{
+		{data: []byte{0x00}, expectedValue: 0, expectedN: 1},
+		{data: []byte{0x7f, 0xff}, expectedValue: 127, expectedN: 1},
+		{data: []byte{0x80, 0x00}, expectedValue: 128, expectedN: 2},
+		{data: []byte{0x81, 0x7f}, expectedValue: 383, expectedN: 2},
+		{data: []byte{0x80}, expectedValue: 0, expectedN: 0},
+		{data: []byte{}, expectedValue: 0, expectedN: 0},
+	}
+
+	for _, test := range tests {
+		value, n := readIndexVarint(test.data)
+		if value != test.expectedValue || n != test.expectedN {
+			t.Errorf("For %v expected (%d, %d), got (%d, %d)", test.data, test.expectedValue, test.expectedN, value, n)
+		}
+	}
+}
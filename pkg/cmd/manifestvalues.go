@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// valuesResource holds fields of a ConfigMap or a Secret, that hold values
+type valuesResource struct {
+	Kind     string `yaml:"kind"`
+	Metadata struct {
+		Name string `yaml:"name"`
+	} `yaml:"metadata"`
+	Data       map[string]string `yaml:"data"`
+	StringData map[string]string `yaml:"stringData"`
+	BinaryData map[string]string `yaml:"binaryData"`
+}
+
+// LoadManifestValues reads keys of ConfigMaps and Secrets from manifests on disk into variables,
+// values of Secrets are decoded from base64. Documents of other kinds are skipped,
+// a value of a later document overrides the one of an earlier document.
+func LoadManifestValues(filenames []string) (map[string]string, error) {
+	vars := make(map[string]string)
+	for _, filename := range filenames {
+		filename = strings.TrimSpace(filename)
+		if filename == "" {
+			continue
+		}
+		content, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		if err := parseManifestValues(content, vars); err != nil {
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+	}
+	return vars, nil
+}
+
+// parseManifestValues parses a stream of manifests, keys of ConfigMaps and Secrets are added to vars
+func parseManifestValues(content []byte, vars map[string]string) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for {
+		var document yaml.Node
+		err := decoder.Decode(&document)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// the kind is decoded first, so documents of other kinds may have data of any structure
+		var header struct {
+			Kind     string `yaml:"kind"`
+			Metadata struct {
+				Name string `yaml:"name"`
+			} `yaml:"metadata"`
+		}
+		if document.Kind == 0 || document.Decode(&header) != nil || (header.Kind != "ConfigMap" && header.Kind != "Secret") {
+			continue
+		}
+		var resource valuesResource
+		if err := document.Decode(&resource); err != nil {
+			return fmt.Errorf("%s %s: %w", strings.ToLower(header.Kind), header.Metadata.Name, err)
+		}
+
+		switch resource.Kind {
+		case "ConfigMap":
+			if err := addManifestValues(vars, resource.BinaryData, true); err != nil {
+				return fmt.Errorf("configmap %s: %w", resource.Metadata.Name, err)
+			}
+			if err := addManifestValues(vars, resource.Data, false); err != nil {
+				return fmt.Errorf("configmap %s: %w", resource.Metadata.Name, err)
+			}
+		case "Secret":
+			// stringData takes precedence over data, the same way as in the API server
+			if err := addManifestValues(vars, resource.Data, true); err != nil {
+				return fmt.Errorf("secret %s: %w", resource.Metadata.Name, err)
+			}
+			if err := addManifestValues(vars, resource.StringData, false); err != nil {
+				return fmt.Errorf("secret %s: %w", resource.Metadata.Name, err)
+			}
+		}
+	}
+}
+
+// addManifestValues adds keys, that are valid variable names or paths, to vars
+func addManifestValues(vars, data map[string]string, encoded bool) error {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if !isPath(key) {
+			continue
+		}
+		value := data[key]
+		if encoded {
+			decoded, err := base64.StdEncoding.DecodeString(value)
+			if err != nil {
+				return fmt.Errorf("key %s: invalid base64 value", key)
+			}
+			value = string(decoded)
+		}
+		vars[key] = value
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseManifestValues(t *testing.T) {
+	content := `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app-config
+data:
+  APP_ENV: prod
+  APP_REPLICAS: 3
+  db.host: db.local
+  app.properties-file: skipped
+binaryData:
+  APP_BINARY: aGVsbG8=
+---
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: app-secret
+type: Opaque
+data:
+  APP_PASSWORD: c2VjcmV0
+  APP_ENV: c3RhZ2U=
+stringData:
+  APP_TOKEN: token
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  replicas: 1
+---
+apiVersion: example.com/v1
+kind: Custom
+metadata:
+  name: custom
+data:
+  nested:
+    a: 1
+`
+	vars := map[string]string{}
+	if err := parseManifestValues([]byte(content), vars); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := map[string]string{
+		"APP_ENV":      "stage",
+		"APP_REPLICAS": "3",
+		"APP_BINARY":   "hello",
+		"APP_PASSWORD": "secret",
+		"APP_TOKEN":    "token",
+		"db.host":      "db.local",
+	}
+	if !reflect.DeepEqual(vars, expected) {
+		t.Errorf("Expected %v, got %v", expected, vars)
+	}
+}
+
+func TestParseManifestValues_StringDataWins(t *testing.T) {
+	content := `
+kind: Secret
+metadata:
+  name: app-secret
+data:
+  APP_PASSWORD: ZnJvbS1kYXRh
+stringData:
+  APP_PASSWORD: from-string-data
+`
+	vars := map[string]string{}
+	if err := parseManifestValues([]byte(content), vars); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if vars["APP_PASSWORD"] != "from-string-data" {
+		t.Errorf("Expected 'from-string-data', got '%s'", vars["APP_PASSWORD"])
+	}
+}
+
+func TestParseManifestValues_Errors(t *testing.T) {
+	tests := []struct {
+		name          string
+		content       string
+		expectedError string
+	}{
+		{
+			name:          "Invalid base64 in a Secret",
+			content:       "kind: Secret\nmetadata:\n  name: app-secret\ndata:\n  APP_PASSWORD: not-base64!\n",
+			expectedError: "secret app-secret: key APP_PASSWORD: invalid base64 value",
+		},
+		{
+			name:          "Structured data in a ConfigMap",
+			content:       "kind: ConfigMap\nmetadata:\n  name: app-config\ndata:\n  nested:\n    a: 1\n",
+			expectedError: "configmap app-config: yaml:",
+		},
+		{
+			name:          "Invalid YAML",
+			content:       "kind: ConfigMap\ndata: [",
+			expectedError: "yaml:",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := parseManifestValues([]byte(test.content), map[string]string{})
+			if err == nil || !strings.HasPrefix(err.Error(), test.expectedError) {
+				t.Errorf("Expected error '%s', got '%v'", test.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestLoadManifestValues(t *testing.T) {
+	dir := t.TempDir()
+	cm := filepath.Join(dir, "cm.yaml")
+	secret := filepath.Join(dir, "secret.yaml")
+	writeTestFile(t, cm, "kind: ConfigMap\nmetadata:\n  name: app\ndata:\n  APP_ENV: dev\n  APP_HOST: db.local\n")
+	writeTestFile(t, secret, "kind: Secret\nmetadata:\n  name: app\ndata:\n  APP_ENV: cHJvZA==\n")
+
+	vars, err := LoadManifestValues([]string{cm, "", secret})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// later manifests override earlier ones
+	expected := map[string]string{"APP_ENV": "prod", "APP_HOST": "db.local"}
+	if !reflect.DeepEqual(vars, expected) {
+		t.Errorf("Expected %v, got %v", expected, vars)
+	}
+
+	if _, err := LoadManifestValues([]string{filepath.Join(dir, "missing.yaml")}); err == nil {
+		t.Error("Expected an error for a missing file, but got none")
+	}
+}
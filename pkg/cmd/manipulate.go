@@ -0,0 +1,222 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// String manipulation operators, with bash semantics:
+//
+//	${VAR^^} ${VAR^}          uppercase all characters, or the first one
+//	${VAR,,} ${VAR,}          lowercase all characters, or the first one
+//	${VAR:offset:length}      substring, a negative offset counts from the end: ${VAR: -3}
+//	${VAR#prefix} ${VAR##prefix}  remove the shortest or the longest matching prefix
+//	${VAR%suffix} ${VAR%%suffix}  remove the shortest or the longest matching suffix
+//	${VAR/old/new}            replace the first match, ${VAR//old/new} replaces all matches,
+//	                          ${VAR/#old/new} and ${VAR/%old/new} match at the beginning or at the end
+//
+// Patterns are globs: '*' matches any string, '?' matches any character, '[...]' matches a class.
+
+// changeCase implements ^^, ^, ,, and , operators
+func changeCase(value, operator string) string {
+	switch operator {
+	case "^^":
+		return strings.ToUpper(value)
+	case ",,":
+		return strings.ToLower(value)
+	}
+
+	r, size := utf8.DecodeRuneInString(value)
+	if size == 0 {
+		return value
+	}
+	if operator == "^" {
+		r = unicode.ToUpper(r)
+	} else {
+		r = unicode.ToLower(r)
+	}
+	return string(r) + value[size:]
+}
+
+// substring implements ${VAR:offset} and ${VAR:offset:length}, offsets are counted in characters
+func substring(value, operand string) (string, error) {
+	runes := []rune(value)
+
+	parts := strings.SplitN(operand, ":", 2)
+	offset, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return "", fmt.Errorf("invalid substring offset: %q", parts[0])
+	}
+	if offset < 0 {
+		offset += len(runes)
+	}
+	if offset < 0 || offset > len(runes) {
+		return "", nil
+	}
+
+	end := len(runes)
+	if len(parts) == 2 {
+		length, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return "", fmt.Errorf("invalid substring length: %q", parts[1])
+		}
+		if length < 0 {
+			// a negative length counts from the end, like in bash
+			end += length
+			if end < offset {
+				return "", fmt.Errorf("substring expression < 0: %q", operand)
+			}
+		} else if offset+length < end {
+			end = offset + length
+		}
+	}
+
+	return string(runes[offset:end]), nil
+}
+
+// removePattern implements #, ##, % and %% operators
+func removePattern(value, operator, pattern string) (string, error) {
+	re, err := compileGlob(pattern, true)
+	if err != nil {
+		return "", err
+	}
+
+	switch operator {
+	case "#", "##":
+		if n, ok := matchPrefix(value, re, operator == "##"); ok {
+			return value[n:], nil
+		}
+	case "%", "%%":
+		if n, ok := matchSuffix(value, re, operator == "%%"); ok {
+			return value[:n], nil
+		}
+	}
+	return value, nil
+}
+
+// replacePattern implements /, //, /# and /% operators
+func replacePattern(value, operator, pattern, replacement string) (string, error) {
+	if pattern == "" {
+		return value, nil
+	}
+
+	switch operator {
+	case "/#", "/%":
+		re, err := compileGlob(pattern, true)
+		if err != nil {
+			return "", err
+		}
+		if operator == "/#" {
+			if n, ok := matchPrefix(value, re, true); ok {
+				return replacement + value[n:], nil
+			}
+			return value, nil
+		}
+		if n, ok := matchSuffix(value, re, true); ok {
+			return value[:n] + replacement, nil
+		}
+		return value, nil
+	}
+
+	// unanchored match, the longest one at the leftmost position
+	re, err := compileGlob(pattern, false)
+	if err != nil {
+		return "", err
+	}
+	re.Longest()
+
+	if operator == "//" {
+		return re.ReplaceAllLiteralString(value, replacement), nil
+	}
+	loc := re.FindStringIndex(value)
+	if loc == nil {
+		return value, nil
+	}
+	return value[:loc[0]] + replacement + value[loc[1]:], nil
+}
+
+// matchPrefix returns the length of the shortest, or the longest prefix of value that matches re
+func matchPrefix(value string, re *regexp.Regexp, longest bool) (int, bool) {
+	for i := 0; i <= len(value); i++ {
+		n := i
+		if longest {
+			n = len(value) - i
+		}
+		if isRuneBoundary(value, n) && re.MatchString(value[:n]) {
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+// matchSuffix returns the offset of the shortest, or the longest suffix of value that matches re
+func matchSuffix(value string, re *regexp.Regexp, longest bool) (int, bool) {
+	for i := 0; i <= len(value); i++ {
+		n := len(value) - i
+		if longest {
+			n = i
+		}
+		if isRuneBoundary(value, n) && re.MatchString(value[n:]) {
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+// compileGlob converts a glob pattern to a regular expression, that matches the whole string when anchored
+func compileGlob(pattern string, anchored bool) (*regexp.Regexp, error) {
+	var sb strings.Builder
+	sb.WriteString("(?s)")
+	if anchored {
+		sb.WriteString("^(?:")
+	}
+
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch c {
+		case '*':
+			sb.WriteString(".*")
+		case '?':
+			sb.WriteString(".")
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+				sb.WriteString(regexp.QuoteMeta(string(pattern[i])))
+			} else {
+				sb.WriteString(`\\`)
+			}
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				sb.WriteString(`\[`)
+				continue
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	if anchored {
+		sb.WriteString(")$")
+	}
+	re, err := regexp.Compile(sb.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %q", pattern)
+	}
+	return re, nil
+}
+
+// isRuneBoundary checks that the offset does not split a multibyte character
+func isRuneBoundary(s string, offset int) bool {
+	return offset >= len(s) || utf8.RuneStart(s[offset])
+}
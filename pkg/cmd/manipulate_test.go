@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"os"
+	"testing"
+)
+
+func TestChangeCase(t *testing.T) {
+	tests := []struct {
+		value    string
+		operator string
+		expected string
+	}{
+		{"feature/Abc-12", "^^", "FEATURE/ABC-12"},
+		{"feature/Abc-12", "^", "Feature/Abc-12"},
+		{"FEATURE/ABC-12", ",,", "feature/abc-12"},
+		{"FEATURE/ABC-12", ",", "fEATURE/ABC-12"},
+		{"", "^", ""},
+		{"ёлка", "^", "Ёлка"},
+	}
+
+	for _, test := range tests {
+		result := changeCase(test.value, test.operator)
+		if result != test.expected {
+			t.Errorf("For %q and %q, expected %q, got %q", test.value, test.operator, test.expected, result)
+		}
+	}
+}
+
+func TestSubstring(t *testing.T) {
+	tests := []struct {
+		value       string
+		operand     string
+		expected    string
+		expectError bool
+	}{
+		{value: "0123456789", operand: "3", expected: "3456789"},
+		{value: "0123456789", operand: "3:2", expected: "34"},
+		{value: "0123456789", operand: "0:100", expected: "0123456789"},
+		{value: "0123456789", operand: " -3", expected: "789"},
+		{value: "0123456789", operand: " -3:2", expected: "78"},
+		{value: "0123456789", operand: "2:-2", expected: "234567"},
+		{value: "0123456789", operand: "20", expected: ""},
+		{value: "0123456789", operand: "8:-5", expectError: true},
+		{value: "0123456789", operand: "abc", expectError: true},
+		{value: "0123456789", operand: "1:abc", expectError: true},
+	}
+
+	for _, test := range tests {
+		result, err := substring(test.value, test.operand)
+		if (err != nil) != test.expectError {
+			t.Errorf("For %q, unexpected error status: %v", test.operand, err)
+			continue
+		}
+		if result != test.expected {
+			t.Errorf("For %q, expected %q, got %q", test.operand, test.expected, result)
+		}
+	}
+}
+
+func TestRemovePattern(t *testing.T) {
+	tests := []struct {
+		value    string
+		operator string
+		pattern  string
+		expected string
+	}{
+		{"registry.local/team/app:1.2.3", "#", "*/", "team/app:1.2.3"},
+		{"registry.local/team/app:1.2.3", "##", "*/", "app:1.2.3"},
+		{"registry.local/team/app:1.2.3", "%", ":*", "registry.local/team/app"},
+		{"app.tar.gz", "%", ".*", "app.tar"},
+		{"app.tar.gz", "%%", ".*", "app"},
+		{"refs/heads/main", "#", "refs/heads/", "main"},
+		{"v1.2.3", "#", "v", "1.2.3"},
+		{"v1.2.3", "#", "[vV]", "1.2.3"},
+		{"v1.2.3", "#", "x", "v1.2.3"},
+	}
+
+	for _, test := range tests {
+		result, err := removePattern(test.value, test.operator, test.pattern)
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+			continue
+		}
+		if result != test.expected {
+			t.Errorf("For %q%s%q, expected %q, got %q", test.value, test.operator, test.pattern, test.expected, result)
+		}
+	}
+}
+
+func TestReplacePattern(t *testing.T) {
+	tests := []struct {
+		value       string
+		operator    string
+		pattern     string
+		replacement string
+		expected    string
+	}{
+		{"feature/ABC/12", "/", "/", "-", "feature-ABC/12"},
+		{"feature/ABC/12", "//", "/", "-", "feature-ABC-12"},
+		{"feature/ABC/12", "//", "\\/", "-", "feature-ABC-12"},
+		{"feature/ABC/12", "/#", "feature", "bugfix", "bugfix/ABC/12"},
+		{"feature/ABC/12", "/%", "12", "13", "feature/ABC/13"},
+		{"feature/ABC/12", "/", "A*/", "", "feature/12"},
+		{"a.b.c", "//", "?", "x", "xxxxx"},
+		{"a.b.c", "//", "[.]", "", "abc"},
+		{"a.b.c", "/", "", "x", "a.b.c"},
+	}
+
+	for _, test := range tests {
+		result, err := replacePattern(test.value, test.operator, test.pattern, test.replacement)
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+			continue
+		}
+		if result != test.expected {
+			t.Errorf("For %q%s%q/%q, expected %q, got %q", test.value, test.operator, test.pattern, test.replacement, test.expected, result)
+		}
+	}
+}
+
+func TestSubstituteEnvs_StringManipulation(t *testing.T) {
+	os.Setenv("APP_BRANCH", "feature/ABC-12")
+	os.Setenv("APP_IMAGE", "registry.local/team/app:1.2.3")
+	os.Setenv("APP_SEP", "/")
+	defer os.Unsetenv("APP_BRANCH")
+	defer os.Unsetenv("APP_IMAGE")
+	defer os.Unsetenv("APP_SEP")
+
+	tests := []struct {
+		name        string
+		input       string
+		expected    string
+		expectError bool
+	}{
+		{name: "Uppercase", input: "${APP_BRANCH^^}", expected: "FEATURE/ABC-12"},
+		{name: "Lowercase", input: "${APP_BRANCH,,}", expected: "feature/abc-12"},
+		{name: "Substring", input: "${APP_BRANCH:8:3}", expected: "ABC"},
+		{name: "Negative offset", input: "${APP_BRANCH: -2}", expected: "12"},
+		{name: "Remove prefix", input: "${APP_IMAGE##*/}", expected: "app:1.2.3"},
+		{name: "Remove suffix", input: "${APP_IMAGE%:*}", expected: "registry.local/team/app"},
+		{name: "Replace all", input: "${APP_BRANCH//\\//-}", expected: "feature-ABC-12"},
+		{name: "Replace with reference", input: "${APP_BRANCH/$APP_SEP/-}", expected: "feature-ABC-12"},
+		{name: "Remove match", input: "${APP_BRANCH/\\/*}", expected: "feature"},
+		{name: "Not in filter", input: "${PATH//:/ } ${HOME##*/}", expected: "${PATH//:/ } ${HOME##*/}"},
+		{name: "Unresolved in strict mode", input: "${APP_UNSET^^}", expectError: true},
+		{name: "Invalid substring", input: "${APP_BRANCH:abc}", expectError: true},
+		{name: "Unexpected operand", input: "${APP_BRANCH^^abc}", expectError: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			envsubst := NewEnvsubst([]string{}, []string{"APP_"}, true)
+			result, err := envsubst.SubstituteEnvs(test.input)
+			if (err != nil) != test.expectError {
+				t.Fatalf("Unexpected error status: got %v, want error=%v", err, test.expectError)
+			}
+			if result != test.expected {
+				t.Errorf("Expected '%s', got '%s'", test.expected, result)
+			}
+		})
+	}
+}
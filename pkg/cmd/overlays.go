@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// OverlayFilename is the name of env files, that hold variables of manifests of a directory and its subdirectories
+const OverlayFilename = ".envsubst.env"
+
+// OverlayReader reads variables of overlays of a manifest: .envsubst.env files in its directory and ancestors.
+// Files are merged from the farthest one, so the nearest file wins, and may refer to variables of farther ones.
+type OverlayReader struct {
+	key []byte
+
+	mu   sync.Mutex
+	dirs map[string]map[string]string
+}
+
+// NewOverlayReader creates a reader of overlays, encrypted overlays are decrypted with the key (optional)
+func NewOverlayReader(key []byte) *OverlayReader {
+	return &OverlayReader{key: key, dirs: make(map[string]map[string]string)}
+}
+
+// Vars returns variables of overlays of a manifest, overlays of the current directory are used
+// for remote manifests and stdin
+func (r *OverlayReader) Vars(filename string) (map[string]string, error) {
+	dir := filepath.Dir(filename)
+	if IsURL(filename) {
+		dir = "."
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.dirVars(abs)
+}
+
+// dirVars returns merged variables of overlays of a directory and its ancestors, results are cached per directory
+func (r *OverlayReader) dirVars(dir string) (map[string]string, error) {
+	if vars, ok := r.dirs[dir]; ok {
+		return vars, nil
+	}
+
+	vars := make(map[string]string)
+	if parent := filepath.Dir(dir); parent != dir {
+		parentVars, err := r.dirVars(parent)
+		if err != nil {
+			return nil, err
+		}
+		for name, value := range parentVars {
+			vars[name] = value
+		}
+	}
+
+	filename := filepath.Join(dir, OverlayFilename)
+	if _, err := os.Stat(filename); err == nil {
+		if err := loadEnvFile(filename, r.key, vars); err != nil {
+			return nil, err
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	r.dirs[dir] = vars
+	return vars, nil
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestOverlayReader(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, OverlayFilename), "APP_ENV=common\nAPP_REPLICAS=1\nAPP_DB=db.${APP_ENV}\n")
+	writeTestFile(t, filepath.Join(dir, "prod", OverlayFilename), "APP_ENV=prod\nAPP_HOST=${APP_ENV}.${APP_DB}\n")
+	writeTestFile(t, filepath.Join(dir, "prod", "eu", OverlayFilename), "APP_REPLICAS=3\n")
+	writeTestFile(t, filepath.Join(dir, "prod", "eu", "deployment.yaml"), "")
+	writeTestFile(t, filepath.Join(dir, "dev", "deployment.yaml"), "")
+
+	tests := []struct {
+		name     string
+		filename string
+		expected map[string]string
+	}{
+		{
+			name:     "Nearest overlay wins",
+			filename: filepath.Join(dir, "prod", "eu", "deployment.yaml"),
+			expected: map[string]string{
+				"APP_ENV":      "prod",
+				"APP_REPLICAS": "3",
+				"APP_DB":       "db.common",
+				"APP_HOST":     "prod.db.common",
+			},
+		},
+		{
+			name:     "Overlay of an ancestor",
+			filename: filepath.Join(dir, "dev", "deployment.yaml"),
+			expected: map[string]string{
+				"APP_ENV":      "common",
+				"APP_REPLICAS": "1",
+				"APP_DB":       "db.common",
+			},
+		},
+	}
+
+	reader := NewOverlayReader(nil)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			vars, err := reader.Vars(test.filename)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(vars, test.expected) {
+				t.Errorf("Expected %v, got %v", test.expected, vars)
+			}
+		})
+	}
+}
+
+func TestOverlayReader_Encrypted(t *testing.T) {
+	dir := t.TempDir()
+	encrypted, err := Encrypt(testKey, []byte("APP_PASSWORD=s3cr3t\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, OverlayFilename), encrypted, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(dir, "deployment.yaml")
+
+	vars, err := NewOverlayReader(testKey).Vars(filename)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if vars["APP_PASSWORD"] != "s3cr3t" {
+		t.Errorf("Expected 's3cr3t', got '%s'", vars["APP_PASSWORD"])
+	}
+
+	expectedError := filepath.Join(dir, OverlayFilename) + ": the file is encrypted, a decryption key is required"
+	if _, err := NewOverlayReader(nil).Vars(filename); err == nil || err.Error() != expectedError {
+		t.Errorf("Expected error '%s', got '%v'", expectedError, err)
+	}
+}
+
+func TestOverlayReader_Errors(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, OverlayFilename), "APP_NAME='unterminated\n")
+
+	if _, err := NewOverlayReader(nil).Vars(filepath.Join(dir, "deployment.yaml")); err == nil {
+		t.Error("Expected an error for a malformed overlay, but got none")
+	}
+}
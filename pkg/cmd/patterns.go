@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Patterns of names of variables are globs, or regular expressions in slashes, a leading '!' negates a pattern:
+//
+//	*_IMAGE         names ending with _IMAGE
+//	APP_?           APP_ followed by a single character
+//	/^(CI|APP)_/    a regular expression, it is not anchored implicitly
+//	!APP_DEBUG      excludes names matching the pattern from other patterns
+
+// namePattern is a parsed pattern of names of variables
+type namePattern struct {
+	raw    string
+	glob   string
+	re     *regexp.Regexp
+	negate bool
+}
+
+// parsePatterns parses patterns of names of variables, empty patterns are skipped
+func parsePatterns(patterns []string) ([]namePattern, error) {
+	result := []namePattern{}
+	for _, raw := range patterns {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		p := namePattern{raw: raw}
+		value := raw
+		if strings.HasPrefix(value, "!") {
+			p.negate = true
+			value = value[1:]
+		}
+
+		if len(value) >= 2 && strings.HasPrefix(value, "/") && strings.HasSuffix(value, "/") {
+			re, err := regexp.Compile(value[1 : len(value)-1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %s: %w", raw, err)
+			}
+			p.re = re
+		} else {
+			if _, err := path.Match(value, ""); err != nil || value == "" {
+				return nil, fmt.Errorf("invalid pattern %s", raw)
+			}
+			p.glob = value
+		}
+		result = append(result, p)
+	}
+	return result, nil
+}
+
+// match checks whether a name matches the pattern, a path matches with its root: images[0].tag for *S
+func (p namePattern) match(name string) bool {
+	if p.matchName(name) {
+		return true
+	}
+	if root := pathRoot(name); root != name {
+		return p.matchName(root)
+	}
+	return false
+}
+
+func (p namePattern) matchName(name string) bool {
+	if p.re != nil {
+		return p.re.MatchString(name)
+	}
+	matched, _ := path.Match(p.glob, name)
+	return matched
+}
+
+// matchPatterns returns the first pattern, that allows a name, negated patterns take precedence
+func matchPatterns(patterns []namePattern, name string) (string, bool) {
+	allowed := ""
+	for _, p := range patterns {
+		if !p.match(name) {
+			continue
+		}
+		if p.negate {
+			return "", false
+		}
+		if allowed == "" {
+			allowed = p.raw
+		}
+	}
+	return allowed, allowed != ""
+}
+
+// pathRoot returns the root variable of a path: db for db.primary.host, images for images[0].tag
+func pathRoot(name string) string {
+	if i := strings.IndexAny(name, ".["); i > 0 {
+		return name[:i]
+	}
+	return name
+}
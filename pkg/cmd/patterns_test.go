@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"testing"
+)
+
+func TestMatchPatterns(t *testing.T) {
+	patterns, err := parsePatterns([]string{"*_IMAGE", "APP_*", "!APP_DEBUG", " ", "/^CI_(JOB|PIPELINE)_ID$/", "images"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{name: "NGINX_IMAGE", expected: "*_IMAGE"},
+		{name: "APP_NAME", expected: "APP_*"},
+		{name: "APP_IMAGE", expected: "*_IMAGE"},
+		{name: "APP_DEBUG", expected: ""},
+		{name: "CI_JOB_ID", expected: "/^CI_(JOB|PIPELINE)_ID$/"},
+		{name: "CI_JOB_TOKEN", expected: ""},
+		{name: "images[0].tag", expected: "images"},
+		{name: "HOME", expected: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pattern, ok := matchPatterns(patterns, test.name)
+			if pattern != test.expected || ok != (test.expected != "") {
+				t.Errorf("Expected '%s', got '%s' (%v)", test.expected, pattern, ok)
+			}
+		})
+	}
+}
+
+func TestParsePatterns_Errors(t *testing.T) {
+	tests := []struct {
+		pattern       string
+		expectedError string
+	}{
+		{pattern: "APP_[", expectedError: "invalid pattern APP_["},
+		{pattern: "!", expectedError: "invalid pattern !"},
+		{pattern: "/APP_(/", expectedError: "invalid pattern /APP_(/: error parsing regexp: missing closing ): `APP_(`"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.pattern, func(t *testing.T) {
+			_, err := parsePatterns([]string{test.pattern})
+			if err == nil || err.Error() != test.expectedError {
+				t.Errorf("Expected error '%s', got '%v'", test.expectedError, err)
+			}
+		})
+	}
+}
@@ -3,23 +3,60 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 )
 
 const (
 	envsubstAllowedVarsEnv     = "ENVSUBST_ALLOWED_VARS"
 	envsubstAllowedPrefixesEnv = "ENVSUBST_ALLOWED_PREFIXES"
+	envsubstAllowedPatternsEnv = "ENVSUBST_ALLOWED_PATTERNS"
+	envsubstDeniedVarsEnv      = "ENVSUBST_DENIED_VARS"
+	envsubstDeniedPrefixesEnv  = "ENVSUBST_DENIED_PREFIXES"
+	envsubstDeniedPolicyEnv    = "ENVSUBST_DENIED_POLICY"
+	envsubstStrictEnv          = "ENVSUBST_STRICT"
+	envsubstDelimitersEnv      = "ENVSUBST_DELIMITERS"
+	envsubstBracesOnlyEnv      = "ENVSUBST_BRACES_ONLY"
+	envsubstEnvFilesEnv        = "ENVSUBST_ENV_FILES"
+	envsubstValuesEnv          = "ENVSUBST_VALUES"
+	envsubstFileRootEnv        = "ENVSUBST_FILE_ROOT"
+	envsubstValuesManifestsEnv = "ENVSUBST_VALUES_FROM_MANIFESTS"
+	envsubstConfigEnv          = "ENVSUBST_CONFIG"
+	envsubstGitEnv             = "ENVSUBST_GIT"
+	envsubstVaultEnv           = "ENVSUBST_VAULT"
+	envsubstSeedEnv            = "ENVSUBST_SEED"
+	envsubstDecryptKeyEnv      = "ENVSUBST_DECRYPT_KEY"
+	envsubstExplainEnv         = "ENVSUBST_EXPLAIN"
+	envsubstExplainValuesEnv   = "ENVSUBST_EXPLAIN_VALUES"
 )
 
 type ArgsRawRecognized struct {
-	Filenames             []string
-	EnvsubstAllowedVars   []string
-	EnvsubstAllowedPrefix []string
-	Recursive             bool
-	Help                  bool
-	Others                []string
-	HasStdin              bool
-	Version               bool
+	Filenames               []string
+	EnvsubstAllowedVars     []string
+	EnvsubstAllowedPrefix   []string
+	EnvsubstAllowedPatterns []string
+	EnvsubstDeniedVars      []string
+	EnvsubstDeniedPrefixes  []string
+	EnvsubstDeniedPolicy    string
+	EnvsubstStrict          string
+	EnvsubstDelimiters      string
+	EnvsubstBracesOnly      bool
+	EnvsubstEnvFiles        []string
+	EnvsubstValuesFiles     []string
+	EnvsubstFileRoot        string
+	EnvsubstManifests       []string
+	EnvsubstConfig          string
+	EnvsubstGit             bool
+	EnvsubstVault           bool
+	EnvsubstSeed            string
+	EnvsubstDecryptKey      string
+	EnvsubstExplain         bool
+	EnvsubstExplainValues   bool
+	Recursive               bool
+	Help                    bool
+	Others                  []string
+	HasStdin                bool
+	Version                 bool
 }
 
 func allEmpty(values []string) bool {
@@ -31,99 +68,260 @@ func allEmpty(values []string) bool {
 	return true
 }
 
+// valueFlag is a flag with a value, that is given as --flag=value or --flag value,
+// its environment variable is used, when the flag is not given
+type valueFlag struct {
+	names []string
+	env   string
+	// split is set for environment variables with comma-separated values of a flag, that takes a single value
+	split  bool
+	handle func(value string, result *ArgsRawRecognized) error
+}
+
+// boolFlag is a flag without a value, its environment variable is used, when the flag is not given
+type boolFlag struct {
+	names  []string
+	env    string
+	target func(result *ArgsRawRecognized) *bool
+}
+
+var valueFlags = []valueFlag{
+	{names: []string{"--filename", "-f"}, handle: handleFilename},
+	{names: []string{"--envsubst-allowed-vars"}, env: envsubstAllowedVarsEnv, handle: handleList(func(r *ArgsRawRecognized) *[]string { return &r.EnvsubstAllowedVars })},
+	{names: []string{"--envsubst-allowed-prefixes"}, env: envsubstAllowedPrefixesEnv, handle: handleList(func(r *ArgsRawRecognized) *[]string { return &r.EnvsubstAllowedPrefix })},
+	{names: []string{"--envsubst-allowed-patterns"}, env: envsubstAllowedPatternsEnv, handle: handlePatterns},
+	{names: []string{"--envsubst-denied-vars"}, env: envsubstDeniedVarsEnv, handle: handleList(func(r *ArgsRawRecognized) *[]string { return &r.EnvsubstDeniedVars })},
+	{names: []string{"--envsubst-denied-prefixes"}, env: envsubstDeniedPrefixesEnv, handle: handleList(func(r *ArgsRawRecognized) *[]string { return &r.EnvsubstDeniedPrefixes })},
+	{names: []string{"--envsubst-denied-policy"}, env: envsubstDeniedPolicyEnv, handle: handleDeniedPolicy},
+	{names: []string{"--envsubst-strict"}, env: envsubstStrictEnv, handle: handleStrict},
+	{names: []string{"--envsubst-delimiters"}, env: envsubstDelimitersEnv, handle: handleDelimiters},
+	{names: []string{"--envsubst-env-file"}, env: envsubstEnvFilesEnv, split: true, handle: handleEnvFile},
+	{names: []string{"--envsubst-values"}, env: envsubstValuesEnv, split: true, handle: handleValuesFile},
+	{names: []string{"--envsubst-values-from-manifest"}, env: envsubstValuesManifestsEnv, split: true, handle: handleValuesManifest},
+	{names: []string{"--envsubst-config"}, env: envsubstConfigEnv, handle: handleConfig},
+	{names: []string{"--envsubst-seed"}, env: envsubstSeedEnv, handle: handleSeed},
+	{names: []string{"--envsubst-decrypt-key"}, env: envsubstDecryptKeyEnv, handle: handleDecryptKey},
+	{names: []string{"--envsubst-file-root"}, env: envsubstFileRootEnv, handle: handleFileRoot},
+}
+
+var boolFlags = []boolFlag{
+	{names: []string{"--envsubst-braces-only"}, env: envsubstBracesOnlyEnv, target: func(r *ArgsRawRecognized) *bool { return &r.EnvsubstBracesOnly }},
+	{names: []string{"--envsubst-git"}, env: envsubstGitEnv, target: func(r *ArgsRawRecognized) *bool { return &r.EnvsubstGit }},
+	{names: []string{"--envsubst-vault"}, env: envsubstVaultEnv, target: func(r *ArgsRawRecognized) *bool { return &r.EnvsubstVault }},
+	{names: []string{"--envsubst-explain"}, env: envsubstExplainEnv, target: func(r *ArgsRawRecognized) *bool { return &r.EnvsubstExplain }},
+	{names: []string{"--envsubst-explain-values"}, env: envsubstExplainValuesEnv, target: func(r *ArgsRawRecognized) *bool { return &r.EnvsubstExplainValues }},
+	{names: []string{"--recursive", "-R"}, target: func(r *ArgsRawRecognized) *bool { return &r.Recursive }},
+	{names: []string{"--help", "-h"}, target: func(r *ArgsRawRecognized) *bool { return &r.Help }},
+	{names: []string{"--version"}, target: func(r *ArgsRawRecognized) *bool { return &r.Version }},
+}
+
 func ParseArgs() (ArgsRawRecognized, error) {
 	args := os.Args[1:] // Skip the program name
 	var result ArgsRawRecognized
 
+	// flags given in the command line, their environment variables are ignored
+	given := map[string]bool{}
+
 	for i := 0; i < len(args); i++ {
 		arg := args[i]
-		switch {
-		// Handle --filename= or -f=
-		case strings.HasPrefix(arg, "--filename="), strings.HasPrefix(arg, "-f="):
-			if err := handleFilename(strings.SplitN(arg, "=", 2)[1], &result); err != nil {
-				return result, err
-			}
 
-		// Handle --filename or -f with a separate value
-		case arg == "--filename" || arg == "-f":
-			if i+1 >= len(args) || args[i+1] == "" {
-				return result, fmt.Errorf("missing value for flag %s", arg)
+		// Handle --flag=value, and --flag with a separate value
+		if flag, value, inline := lookupValueFlag(arg); flag != nil {
+			if !inline {
+				if i+1 >= len(args) || args[i+1] == "" {
+					return result, fmt.Errorf("missing value for flag %s", arg)
+				}
+				value = args[i+1]
+				i++ // Skip the next argument
 			}
-			if err := handleFilename(args[i+1], &result); err != nil {
+			if err := flag.handle(value, &result); err != nil {
 				return result, err
 			}
-			i++ // Skip the next argument
+			given[flag.names[0]] = true
+			continue
+		}
 
-		// Handle --envsubst-allowed-vars=
-		case strings.HasPrefix(arg, "--envsubst-allowed-vars="):
-			list, err := appendList(strings.TrimPrefix(arg, "--envsubst-allowed-vars="))
-			if err != nil {
-				return result, err
-			}
-			result.EnvsubstAllowedVars = append(result.EnvsubstAllowedVars, list...)
+		// Handle boolean flags
+		if flag := lookupBoolFlag(arg); flag != nil {
+			*flag.target(&result) = true
+			given[flag.names[0]] = true
+			continue
+		}
 
-		// Handle --envsubst-allowed-vars with a separate value
-		case arg == "--envsubst-allowed-vars":
-			if i+1 >= len(args) || args[i+1] == "" {
-				return result, fmt.Errorf("missing value for flag %s", arg)
+		// Handle unrecognized arguments
+		result.Others = append(result.Others, arg)
+	}
+
+	// Load values of flags, that are not given, from environment variables
+	for i := range valueFlags {
+		flag := &valueFlags[i]
+		if flag.env == "" || given[flag.names[0]] {
+			continue
+		}
+		if err := loadEnvFlag(flag, &result); err != nil {
+			return result, err
+		}
+	}
+	for i := range boolFlags {
+		flag := &boolFlags[i]
+		if flag.env == "" || given[flag.names[0]] {
+			continue
+		}
+		if err := loadEnvBool(flag.env, flag.target(&result)); err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// lookupValueFlag returns a flag of the argument, and its value, when it is given as --flag=value
+func lookupValueFlag(arg string) (flag *valueFlag, value string, inline bool) {
+	for i := range valueFlags {
+		for _, name := range valueFlags[i].names {
+			if arg == name {
+				return &valueFlags[i], "", false
 			}
-			list, err := appendList(args[i+1])
-			if err != nil {
-				return result, err
+			if strings.HasPrefix(arg, name+"=") {
+				return &valueFlags[i], arg[len(name)+1:], true
 			}
-			result.EnvsubstAllowedVars = append(result.EnvsubstAllowedVars, list...)
-			i++ // Skip the next argument
+		}
+	}
+	return nil, "", false
+}
 
-		// Handle --envsubst-allowed-prefixes=
-		case strings.HasPrefix(arg, "--envsubst-allowed-prefixes="):
-			list, err := appendList(strings.TrimPrefix(arg, "--envsubst-allowed-prefixes="))
-			if err != nil {
-				return result, err
+// lookupBoolFlag returns a boolean flag of the argument
+func lookupBoolFlag(arg string) *boolFlag {
+	for i := range boolFlags {
+		for _, name := range boolFlags[i].names {
+			if arg == name {
+				return &boolFlags[i]
 			}
-			result.EnvsubstAllowedPrefix = append(result.EnvsubstAllowedPrefix, list...)
+		}
+	}
+	return nil
+}
 
-		// Handle --envsubst-allowed-prefixes with a separate value
-		case arg == "--envsubst-allowed-prefixes":
-			if i+1 >= len(args) || args[i+1] == "" {
-				return result, fmt.Errorf("missing value for flag %s", arg)
-			}
-			list, err := appendList(args[i+1])
-			if err != nil {
-				return result, err
-			}
-			result.EnvsubstAllowedPrefix = append(result.EnvsubstAllowedPrefix, list...)
-			i++ // Skip the next argument
+// loadEnvFlag handles the value of an environment variable of a flag, if it is set
+func loadEnvFlag(flag *valueFlag, result *ArgsRawRecognized) error {
+	values := []string{}
+	if err := loadEnvVars(flag.env, &values); err != nil || len(values) == 0 {
+		return err
+	}
+	if !flag.split {
+		values = []string{os.Getenv(flag.env)}
+	}
+	for _, value := range values {
+		if flag.split && strings.TrimSpace(value) == "" {
+			continue
+		}
+		if err := flag.handle(value, result); err != nil {
+			return fmt.Errorf("%s: %w", flag.env, err)
+		}
+	}
+	return nil
+}
 
-		// Handle boolean flags
+// handleList returns a handler of a flag with a comma-separated list of values
+func handleList(target func(result *ArgsRawRecognized) *[]string) func(string, *ArgsRawRecognized) error {
+	return func(value string, result *ArgsRawRecognized) error {
+		list, err := appendList(value)
+		if err != nil {
+			return err
+		}
+		*target(result) = append(*target(result), list...)
+		return nil
+	}
+}
 
-		case arg == "--recursive" || arg == "-R":
-			result.Recursive = true
+// handlePatterns handles a comma-separated list of patterns, commas of regular expressions do not separate them
+func handlePatterns(value string, result *ArgsRawRecognized) error {
+	list, err := splitPatterns(value)
+	if err != nil {
+		return err
+	}
+	result.EnvsubstAllowedPatterns = append(result.EnvsubstAllowedPatterns, list...)
+	return nil
+}
 
-		case arg == "--help" || arg == "-h":
-			result.Help = true
+func handleDelimiters(value string, result *ArgsRawRecognized) error {
+	if _, err := parseDelimiters(value); err != nil {
+		return err
+	}
+	result.EnvsubstDelimiters = value
+	return nil
+}
 
-		case arg == "--version":
-			result.Version = true
+func handleEnvFile(filename string, result *ArgsRawRecognized) error {
+	if filename == "" {
+		return fmt.Errorf("missing env file value")
+	}
+	result.EnvsubstEnvFiles = append(result.EnvsubstEnvFiles, filename)
+	return nil
+}
 
-		// Handle unrecognized arguments
-		default:
-			result.Others = append(result.Others, arg)
-		}
+func handleValuesFile(filename string, result *ArgsRawRecognized) error {
+	if filename == "" {
+		return fmt.Errorf("missing values file value")
 	}
+	result.EnvsubstValuesFiles = append(result.EnvsubstValuesFiles, filename)
+	return nil
+}
 
-	// Load allowed vars and prefixes from environment variables
-	if len(result.EnvsubstAllowedVars) == 0 {
-		if err := loadEnvVars(envsubstAllowedVarsEnv, &result.EnvsubstAllowedVars); err != nil {
-			return result, err
-		}
+func handleValuesManifest(filename string, result *ArgsRawRecognized) error {
+	if filename == "" {
+		return fmt.Errorf("missing values manifest value")
 	}
-	if len(result.EnvsubstAllowedPrefix) == 0 {
-		if err := loadEnvVars(envsubstAllowedPrefixesEnv, &result.EnvsubstAllowedPrefix); err != nil {
-			return result, err
-		}
+	result.EnvsubstManifests = append(result.EnvsubstManifests, filename)
+	return nil
+}
+
+func handleConfig(filename string, result *ArgsRawRecognized) error {
+	if filename == "" {
+		return fmt.Errorf("missing config file value")
 	}
+	result.EnvsubstConfig = filename
+	return nil
+}
 
-	return result, nil
+func handleStrict(value string, result *ArgsRawRecognized) error {
+	if value != StrictOff && value != StrictFiltered && value != StrictAll {
+		return fmt.Errorf("invalid strict level %q, expected %s, %s or %s", value, StrictOff, StrictFiltered, StrictAll)
+	}
+	result.EnvsubstStrict = value
+	return nil
+}
+
+func handleDeniedPolicy(value string, result *ArgsRawRecognized) error {
+	if value != DeniedPolicyIgnore && value != DeniedPolicyError {
+		return fmt.Errorf("invalid denied policy %q, expected %s or %s", value, DeniedPolicyIgnore, DeniedPolicyError)
+	}
+	result.EnvsubstDeniedPolicy = value
+	return nil
+}
+
+func handleSeed(value string, result *ArgsRawRecognized) error {
+	if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+		return fmt.Errorf("invalid seed %q, expected an integer", value)
+	}
+	result.EnvsubstSeed = value
+	return nil
+}
+
+func handleDecryptKey(filename string, result *ArgsRawRecognized) error {
+	if filename == "" {
+		return fmt.Errorf("missing decrypt key value")
+	}
+	result.EnvsubstDecryptKey = filename
+	return nil
+}
+
+func handleFileRoot(dir string, result *ArgsRawRecognized) error {
+	if dir == "" {
+		return fmt.Errorf("missing file root value")
+	}
+	result.EnvsubstFileRoot = dir
+	return nil
 }
 
 func handleFilename(filename string, result *ArgsRawRecognized) error {
@@ -149,6 +347,51 @@ func appendList(value string) ([]string, error) {
 	return split, nil
 }
 
+// splitPatterns splits a comma-separated list of patterns, a regular expression in slashes spans
+// to its closing slash, so it may hold commas: /^APP_[A-Z]{1,3}$/,*_IMAGE
+func splitPatterns(value string) ([]string, error) {
+	patterns := []string{}
+	rest := value
+	for {
+		item := strings.TrimLeft(rest, " \t")
+		end := strings.IndexByte(item, ',')
+		if expr := strings.TrimPrefix(item, "!"); strings.HasPrefix(expr, "/") {
+			closing := regexpEnd(expr)
+			if closing < 0 {
+				return nil, fmt.Errorf("invalid pattern %s: missing closing '/'", strings.TrimSpace(item))
+			}
+			closing += len(item) - len(expr)
+			if end = strings.IndexByte(item[closing:], ','); end >= 0 {
+				end += closing
+			}
+		}
+		if end < 0 {
+			patterns = append(patterns, item)
+			break
+		}
+		patterns = append(patterns, item[:end])
+		rest = item[end+1:]
+	}
+	if value == "" || allEmpty(patterns) {
+		return nil, fmt.Errorf("empty list value")
+	}
+	return patterns, nil
+}
+
+// regexpEnd returns the offset of the closing slash of a regular expression, that starts with a slash,
+// the closing slash is followed by a comma or by the end of the list, it returns -1 without one
+func regexpEnd(expr string) int {
+	for i := 1; i < len(expr); i++ {
+		if expr[i] != '/' {
+			continue
+		}
+		if after := strings.TrimLeft(expr[i+1:], " \t"); after == "" || after[0] == ',' {
+			return i
+		}
+	}
+	return -1
+}
+
 func loadEnvVars(envKey string, target *[]string) error {
 	value, exists := os.LookupEnv(envKey)
 	if !exists {
@@ -163,3 +406,18 @@ func loadEnvVars(envKey string, target *[]string) error {
 	*target = split
 	return nil
 }
+
+func loadEnvBool(envKey string, target *bool) error {
+	value, exists := os.LookupEnv(envKey)
+	if !exists {
+		return nil
+	}
+
+	parsed, err := strconv.ParseBool(strings.TrimSpace(value))
+	if err != nil {
+		return fmt.Errorf("invalid boolean value for env: %s", envKey)
+	}
+
+	*target = parsed
+	return nil
+}
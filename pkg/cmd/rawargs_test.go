@@ -113,6 +113,186 @@ func TestParseArgs(t *testing.T) {
 			expectedResult: ArgsRawRecognized{EnvsubstAllowedPrefix: []string{"CI_", "APP", "TF_VAR_"}},
 			expectedError:  false,
 		},
+		{
+			name:           "Envsubst allowed patterns with append",
+			args:           []string{"--envsubst-allowed-patterns=*_IMAGE,!APP_DEBUG", "--envsubst-allowed-patterns", "/^CI_/"},
+			expectedResult: ArgsRawRecognized{EnvsubstAllowedPatterns: []string{"*_IMAGE", "!APP_DEBUG", "/^CI_/"}},
+			expectedError:  false,
+		},
+		{
+			name:           "Envsubst allowed patterns with commas in regular expressions",
+			args:           []string{"--envsubst-allowed-patterns=/^A{1,3}$/,*_IMAGE,!/^APP_(X|Y){1,2}$/"},
+			expectedResult: ArgsRawRecognized{EnvsubstAllowedPatterns: []string{"/^A{1,3}$/", "*_IMAGE", "!/^APP_(X|Y){1,2}$/"}},
+			expectedError:  false,
+		},
+		{
+			name:           "Envsubst allowed patterns with unterminated regular expression",
+			args:           []string{"--envsubst-allowed-patterns=/^A{1,3}$,*_IMAGE"},
+			expectedResult: ArgsRawRecognized{},
+			expectedError:  true,
+		},
+		{
+			name:           "Envsubst denied vars and prefixes",
+			args:           []string{"--envsubst-denied-vars=CI_JOB_TOKEN", "--envsubst-denied-prefixes", "CI_REGISTRY_,AWS_", "--envsubst-denied-vars", "GITHUB_TOKEN"},
+			expectedResult: ArgsRawRecognized{EnvsubstDeniedVars: []string{"CI_JOB_TOKEN", "GITHUB_TOKEN"}, EnvsubstDeniedPrefixes: []string{"CI_REGISTRY_", "AWS_"}},
+			expectedError:  false,
+		},
+		{
+			name:           "Envsubst denied policy",
+			args:           []string{"--envsubst-denied-policy", "error"},
+			expectedResult: ArgsRawRecognized{EnvsubstDeniedPolicy: "error"},
+			expectedError:  false,
+		},
+		{
+			name:           "Invalid envsubst denied policy",
+			args:           []string{"--envsubst-denied-policy=warn"},
+			expectedResult: ArgsRawRecognized{},
+			expectedError:  true,
+		},
+		{
+			name:           "Envsubst strict",
+			args:           []string{"--envsubst-strict=all"},
+			expectedResult: ArgsRawRecognized{EnvsubstStrict: "all"},
+			expectedError:  false,
+		},
+		{
+			name:           "Invalid envsubst strict",
+			args:           []string{"--envsubst-strict", "true"},
+			expectedResult: ArgsRawRecognized{},
+			expectedError:  true,
+		},
+		{
+			name:           "Envsubst delimiters",
+			args:           []string{"--envsubst-delimiters={{,}}"},
+			expectedResult: ArgsRawRecognized{EnvsubstDelimiters: "{{,}}"},
+			expectedError:  false,
+		},
+		{
+			name:           "Envsubst delimiters (no =)",
+			args:           []string{"--envsubst-delimiters", "@,@"},
+			expectedResult: ArgsRawRecognized{EnvsubstDelimiters: "@,@"},
+			expectedError:  false,
+		},
+		{
+			name:           "Envsubst braces only",
+			args:           []string{"--envsubst-braces-only"},
+			expectedResult: ArgsRawRecognized{EnvsubstBracesOnly: true},
+			expectedError:  false,
+		},
+		{
+			name:           "Envsubst env files",
+			args:           []string{"--envsubst-env-file=common.env", "--envsubst-env-file", "prod.env"},
+			expectedResult: ArgsRawRecognized{EnvsubstEnvFiles: []string{"common.env", "prod.env"}},
+			expectedError:  false,
+		},
+		{
+			name:           "Empty envsubst env file",
+			args:           []string{"--envsubst-env-file="},
+			expectedResult: ArgsRawRecognized{},
+			expectedError:  true,
+		},
+		{
+			name:           "Envsubst values files",
+			args:           []string{"--envsubst-values=values.yaml", "--envsubst-values", "prod.yaml"},
+			expectedResult: ArgsRawRecognized{EnvsubstValuesFiles: []string{"values.yaml", "prod.yaml"}},
+			expectedError:  false,
+		},
+		{
+			name:           "Empty envsubst values file",
+			args:           []string{"--envsubst-values="},
+			expectedResult: ArgsRawRecognized{},
+			expectedError:  true,
+		},
+		{
+			name:           "Envsubst values from manifests",
+			args:           []string{"--envsubst-values-from-manifest=cm.yaml", "--envsubst-values-from-manifest", "secret.yaml"},
+			expectedResult: ArgsRawRecognized{EnvsubstManifests: []string{"cm.yaml", "secret.yaml"}},
+			expectedError:  false,
+		},
+		{
+			name:           "Empty envsubst values manifest",
+			args:           []string{"--envsubst-values-from-manifest="},
+			expectedResult: ArgsRawRecognized{},
+			expectedError:  true,
+		},
+		{
+			name:           "Envsubst git",
+			args:           []string{"--envsubst-git"},
+			expectedResult: ArgsRawRecognized{EnvsubstGit: true},
+			expectedError:  false,
+		},
+		{
+			name:           "Envsubst vault",
+			args:           []string{"--envsubst-vault"},
+			expectedResult: ArgsRawRecognized{EnvsubstVault: true},
+			expectedError:  false,
+		},
+		{
+			name:           "Envsubst explain",
+			args:           []string{"--envsubst-explain"},
+			expectedResult: ArgsRawRecognized{EnvsubstExplain: true},
+			expectedError:  false,
+		},
+		{
+			name:           "Envsubst explain values",
+			args:           []string{"--envsubst-explain", "--envsubst-explain-values"},
+			expectedResult: ArgsRawRecognized{EnvsubstExplain: true, EnvsubstExplainValues: true},
+			expectedError:  false,
+		},
+		{
+			name:           "Envsubst seed",
+			args:           []string{"--envsubst-seed", "42"},
+			expectedResult: ArgsRawRecognized{EnvsubstSeed: "42"},
+			expectedError:  false,
+		},
+		{
+			name:           "Invalid envsubst seed",
+			args:           []string{"--envsubst-seed=abc"},
+			expectedResult: ArgsRawRecognized{},
+			expectedError:  true,
+		},
+		{
+			name:           "Envsubst config",
+			args:           []string{"--envsubst-config=envsubst.yaml"},
+			expectedResult: ArgsRawRecognized{EnvsubstConfig: "envsubst.yaml"},
+			expectedError:  false,
+		},
+		{
+			name:           "Missing envsubst config",
+			args:           []string{"--envsubst-config"},
+			expectedResult: ArgsRawRecognized{},
+			expectedError:  true,
+		},
+		{
+			name:           "Envsubst decrypt key",
+			args:           []string{"--envsubst-decrypt-key=prod.key"},
+			expectedResult: ArgsRawRecognized{EnvsubstDecryptKey: "prod.key"},
+			expectedError:  false,
+		},
+		{
+			name:           "Missing envsubst decrypt key",
+			args:           []string{"--envsubst-decrypt-key"},
+			expectedResult: ArgsRawRecognized{},
+			expectedError:  true,
+		},
+		{
+			name:           "Envsubst file root",
+			args:           []string{"--envsubst-file-root", "manifests"},
+			expectedResult: ArgsRawRecognized{EnvsubstFileRoot: "manifests"},
+			expectedError:  false,
+		},
+		{
+			name:           "Empty envsubst file root",
+			args:           []string{"--envsubst-file-root="},
+			expectedResult: ArgsRawRecognized{},
+			expectedError:  true,
+		},
+		{
+			name:           "Invalid envsubst delimiters",
+			args:           []string{"--envsubst-delimiters={{"},
+			expectedResult: ArgsRawRecognized{},
+			expectedError:  true,
+		},
 		{
 			name:           "Empty value for --filename",
 			args:           []string{"--filename="},
@@ -391,6 +571,271 @@ func TestParseArgs_ErrorsAndReturns(t *testing.T) {
 			},
 			expectErr: "missing value for env: ENVSUBST_ALLOWED_PREFIXES",
 		},
+		{
+			name:      "Missing value for --envsubst-delimiters",
+			args:      []string{"app", "--envsubst-delimiters"},
+			expectErr: "missing value for flag --envsubst-delimiters",
+		},
+		{
+			name: "Empty environment variable for ENVSUBST_DELIMITERS",
+			args: []string{"app"},
+			envVars: map[string]string{
+				"ENVSUBST_DELIMITERS": " ",
+			},
+			expectErr: "missing value for env: ENVSUBST_DELIMITERS",
+		},
+		{
+			name: "Invalid environment variable for ENVSUBST_DELIMITERS",
+			args: []string{"app"},
+			envVars: map[string]string{
+				"ENVSUBST_DELIMITERS": "{{",
+			},
+			expectErr: `ENVSUBST_DELIMITERS: invalid delimiters "{{", expected opening and closing ones separated by a comma, e.g. {{,}}`,
+		},
+		{
+			name: "Delimiters from environment variable",
+			args: []string{"app"},
+			envVars: map[string]string{
+				"ENVSUBST_DELIMITERS": "%{,}",
+			},
+			validate: func(t *testing.T, result ArgsRawRecognized) {
+				if result.EnvsubstDelimiters != "%{,}" {
+					t.Errorf("Expected EnvsubstDelimiters '%%{,}', got %q", result.EnvsubstDelimiters)
+				}
+			},
+		},
+		{
+			name: "CLI delimiters take precedence over environment variable",
+			args: []string{"app", "--envsubst-delimiters={{,}}"},
+			envVars: map[string]string{
+				"ENVSUBST_DELIMITERS": "%{,}",
+			},
+			validate: func(t *testing.T, result ArgsRawRecognized) {
+				if result.EnvsubstDelimiters != "{{,}}" {
+					t.Errorf("Expected EnvsubstDelimiters '{{,}}', got %q", result.EnvsubstDelimiters)
+				}
+			},
+		},
+		{
+			name: "Invalid environment variable for ENVSUBST_BRACES_ONLY",
+			args: []string{"app"},
+			envVars: map[string]string{
+				"ENVSUBST_BRACES_ONLY": "yes",
+			},
+			expectErr: "invalid boolean value for env: ENVSUBST_BRACES_ONLY",
+		},
+		{
+			name: "Braces only from environment variable",
+			args: []string{"app"},
+			envVars: map[string]string{
+				"ENVSUBST_BRACES_ONLY": "true",
+			},
+			validate: func(t *testing.T, result ArgsRawRecognized) {
+				if !result.EnvsubstBracesOnly {
+					t.Errorf("Expected EnvsubstBracesOnly to be true")
+				}
+			},
+		},
+		{
+			name: "Env files from environment variable",
+			args: []string{"app"},
+			envVars: map[string]string{
+				"ENVSUBST_ENV_FILES": "common.env,prod.env",
+			},
+			validate: func(t *testing.T, result ArgsRawRecognized) {
+				if !reflect.DeepEqual(result.EnvsubstEnvFiles, []string{"common.env", "prod.env"}) {
+					t.Errorf("Expected EnvsubstEnvFiles [common.env prod.env], got %v", result.EnvsubstEnvFiles)
+				}
+			},
+		},
+		{
+			name: "CLI env files take precedence over environment variable",
+			args: []string{"app", "--envsubst-env-file=local.env"},
+			envVars: map[string]string{
+				"ENVSUBST_ENV_FILES": "common.env,prod.env",
+			},
+			validate: func(t *testing.T, result ArgsRawRecognized) {
+				if !reflect.DeepEqual(result.EnvsubstEnvFiles, []string{"local.env"}) {
+					t.Errorf("Expected EnvsubstEnvFiles [local.env], got %v", result.EnvsubstEnvFiles)
+				}
+			},
+		},
+		{
+			name: "Values files from environment variable",
+			args: []string{"app"},
+			envVars: map[string]string{
+				"ENVSUBST_VALUES": "values.yaml,prod.yaml",
+			},
+			validate: func(t *testing.T, result ArgsRawRecognized) {
+				if !reflect.DeepEqual(result.EnvsubstValuesFiles, []string{"values.yaml", "prod.yaml"}) {
+					t.Errorf("Expected EnvsubstValuesFiles [values.yaml prod.yaml], got %v", result.EnvsubstValuesFiles)
+				}
+			},
+		},
+		{
+			name: "Values manifests from environment variable",
+			args: []string{"app"},
+			envVars: map[string]string{
+				"ENVSUBST_VALUES_FROM_MANIFESTS": "cm.yaml,secret.yaml",
+			},
+			validate: func(t *testing.T, result ArgsRawRecognized) {
+				if !reflect.DeepEqual(result.EnvsubstManifests, []string{"cm.yaml", "secret.yaml"}) {
+					t.Errorf("Expected EnvsubstManifests [cm.yaml secret.yaml], got %v", result.EnvsubstManifests)
+				}
+			},
+		},
+		{
+			name: "Git from environment variable",
+			args: []string{"app"},
+			envVars: map[string]string{
+				"ENVSUBST_GIT": "true",
+			},
+			validate: func(t *testing.T, result ArgsRawRecognized) {
+				if !result.EnvsubstGit {
+					t.Errorf("Expected EnvsubstGit to be true")
+				}
+			},
+		},
+		{
+			name: "Vault from environment variable",
+			args: []string{"app"},
+			envVars: map[string]string{
+				"ENVSUBST_VAULT": "true",
+			},
+			validate: func(t *testing.T, result ArgsRawRecognized) {
+				if !result.EnvsubstVault {
+					t.Errorf("Expected EnvsubstVault to be true")
+				}
+			},
+		},
+		{
+			name: "Allowed patterns from environment variable",
+			args: []string{"app"},
+			envVars: map[string]string{
+				"ENVSUBST_ALLOWED_PATTERNS": "*_IMAGE,APP_*",
+			},
+			validate: func(t *testing.T, result ArgsRawRecognized) {
+				if !reflect.DeepEqual(result.EnvsubstAllowedPatterns, []string{"*_IMAGE", "APP_*"}) {
+					t.Errorf("Expected EnvsubstAllowedPatterns [*_IMAGE APP_*], got %v", result.EnvsubstAllowedPatterns)
+				}
+			},
+		},
+		{
+			name: "Allowed patterns with commas from environment variable",
+			args: []string{"app"},
+			envVars: map[string]string{
+				"ENVSUBST_ALLOWED_PATTERNS": "/^A{1,3}$/, *_IMAGE",
+			},
+			validate: func(t *testing.T, result ArgsRawRecognized) {
+				if !reflect.DeepEqual(result.EnvsubstAllowedPatterns, []string{"/^A{1,3}$/", "*_IMAGE"}) {
+					t.Errorf("Expected EnvsubstAllowedPatterns [/^A{1,3}$/ *_IMAGE], got %v", result.EnvsubstAllowedPatterns)
+				}
+			},
+		},
+		{
+			name: "Denied lists from environment variables",
+			args: []string{"app"},
+			envVars: map[string]string{
+				"ENVSUBST_DENIED_VARS":     "CI_JOB_TOKEN",
+				"ENVSUBST_DENIED_PREFIXES": "CI_REGISTRY_",
+				"ENVSUBST_DENIED_POLICY":   "error",
+			},
+			validate: func(t *testing.T, result ArgsRawRecognized) {
+				if !reflect.DeepEqual(result.EnvsubstDeniedVars, []string{"CI_JOB_TOKEN"}) {
+					t.Errorf("Expected EnvsubstDeniedVars [CI_JOB_TOKEN], got %v", result.EnvsubstDeniedVars)
+				}
+				if !reflect.DeepEqual(result.EnvsubstDeniedPrefixes, []string{"CI_REGISTRY_"}) {
+					t.Errorf("Expected EnvsubstDeniedPrefixes [CI_REGISTRY_], got %v", result.EnvsubstDeniedPrefixes)
+				}
+				if result.EnvsubstDeniedPolicy != "error" {
+					t.Errorf("Expected EnvsubstDeniedPolicy 'error', got '%s'", result.EnvsubstDeniedPolicy)
+				}
+			},
+		},
+		{
+			name: "Strict from environment variable",
+			args: []string{"app"},
+			envVars: map[string]string{
+				"ENVSUBST_STRICT": "off",
+			},
+			validate: func(t *testing.T, result ArgsRawRecognized) {
+				if result.EnvsubstStrict != "off" {
+					t.Errorf("Expected EnvsubstStrict 'off', got '%s'", result.EnvsubstStrict)
+				}
+			},
+		},
+		{
+			name: "Explain from environment variable",
+			args: []string{"app"},
+			envVars: map[string]string{
+				"ENVSUBST_EXPLAIN": "true",
+			},
+			validate: func(t *testing.T, result ArgsRawRecognized) {
+				if !result.EnvsubstExplain {
+					t.Errorf("Expected EnvsubstExplain to be true")
+				}
+			},
+		},
+		{
+			name: "Explain values from environment variable",
+			args: []string{"app"},
+			envVars: map[string]string{
+				"ENVSUBST_EXPLAIN_VALUES": "true",
+			},
+			validate: func(t *testing.T, result ArgsRawRecognized) {
+				if !result.EnvsubstExplainValues {
+					t.Errorf("Expected EnvsubstExplainValues to be true")
+				}
+			},
+		},
+		{
+			name: "Seed from environment variable",
+			args: []string{"app"},
+			envVars: map[string]string{
+				"ENVSUBST_SEED": "7",
+			},
+			validate: func(t *testing.T, result ArgsRawRecognized) {
+				if result.EnvsubstSeed != "7" {
+					t.Errorf("Expected EnvsubstSeed '7', got '%s'", result.EnvsubstSeed)
+				}
+			},
+		},
+		{
+			name: "Config from environment variable",
+			args: []string{"app"},
+			envVars: map[string]string{
+				"ENVSUBST_CONFIG": "envsubst.yaml",
+			},
+			validate: func(t *testing.T, result ArgsRawRecognized) {
+				if result.EnvsubstConfig != "envsubst.yaml" {
+					t.Errorf("Expected EnvsubstConfig 'envsubst.yaml', got '%s'", result.EnvsubstConfig)
+				}
+			},
+		},
+		{
+			name: "Decrypt key from environment variable",
+			args: []string{"app"},
+			envVars: map[string]string{
+				"ENVSUBST_DECRYPT_KEY": "prod.key",
+			},
+			validate: func(t *testing.T, result ArgsRawRecognized) {
+				if result.EnvsubstDecryptKey != "prod.key" {
+					t.Errorf("Expected EnvsubstDecryptKey 'prod.key', got '%s'", result.EnvsubstDecryptKey)
+				}
+			},
+		},
+		{
+			name: "File root from environment variable",
+			args: []string{"app"},
+			envVars: map[string]string{
+				"ENVSUBST_FILE_ROOT": "manifests",
+			},
+			validate: func(t *testing.T, result ArgsRawRecognized) {
+				if result.EnvsubstFileRoot != "manifests" {
+					t.Errorf("Expected EnvsubstFileRoot 'manifests', got '%s'", result.EnvsubstFileRoot)
+				}
+			},
+		},
 		{
 			name: "Successful parsing with all flags",
 			args: []string{"app", "--filename=test.yaml", "--envsubst-allowed-vars=VAR1,VAR2", "--envsubst-allowed-prefixes=PREFIX1,PREFIX2", "--recursive", "--help"},
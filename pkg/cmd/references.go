@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Resolver resolves references to external values: ${file:certs/tls.crt},
+// filename is the name of the processed manifest, a local path or a URL
+type Resolver interface {
+	Resolve(ref, filename string) (string, error)
+}
+
+// normalizeReference returns the form of a reference, that is resolved and checked by filter lists:
+// paths of files are cleaned, paths of vault secrets must not hold empty, '.' and '..' segments
+func normalizeReference(scheme, ref string) (string, error) {
+	switch scheme {
+	case "file", "ENVSUBST_HASH":
+		return path.Clean(filepath.ToSlash(ref)), nil
+	case "vault":
+		secretPath, _, _ := strings.Cut(ref, "#")
+		if err := checkVaultPath(secretPath); err != nil {
+			return "", err
+		}
+	}
+	return ref, nil
+}
+
+// FileResolver reads files of references: ${file:certs/tls.crt}.
+// Paths are relative to the directory of a manifest, and must be inside of the root directory,
+// paths of remote manifests are relative to their URLs, and must be inside of the directory of the URL.
+type FileResolver struct {
+	root string
+}
+
+// NewFileResolver creates a resolver of files inside of the root directory
+func NewFileResolver(root string) (*FileResolver, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("file root %s is not a directory", root)
+	}
+	return &FileResolver{root: abs}, nil
+}
+
+func (r *FileResolver) Resolve(ref, filename string) (string, error) {
+	if IsURL(filename) {
+		return r.resolveRemote(ref, filename)
+	}
+
+	target := ref
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(filename), ref)
+	}
+	target, err := filepath.Abs(target)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(r.root, target)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("file %s is outside of the root directory %s", ref, r.root)
+	}
+
+	// the root also prevents escaping through symlinks
+	root, err := os.OpenRoot(r.root)
+	if err != nil {
+		return "", err
+	}
+	defer root.Close()
+	f, err := root.Open(rel)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("file %s does not exist", ref)
+	}
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	content, err := io.ReadAll(f)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+// resolveRemote fetches a file relative to the URL of a manifest
+func (r *FileResolver) resolveRemote(ref, manifestURL string) (string, error) {
+	base, err := url.Parse(manifestURL)
+	if err != nil {
+		return "", err
+	}
+	refURL, err := url.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	target := base.ResolveReference(refURL)
+	dir := path.Dir(base.Path)
+	if !strings.HasSuffix(dir, "/") {
+		dir += "/"
+	}
+	if target.Scheme != base.Scheme || target.Host != base.Host || !strings.HasPrefix(target.Path, dir) {
+		return "", fmt.Errorf("file %s is outside of the directory of %s", ref, manifestURL)
+	}
+	content, err := ReadRemoteFileContent(target.String())
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileResolver(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	writeTestFile(t, filepath.Join(root, "manifests", "app", "deployment.yaml"), "kind: Deployment")
+	writeTestFile(t, filepath.Join(root, "manifests", "certs", "tls.crt"), "-----BEGIN CERTIFICATE-----\n")
+	writeTestFile(t, filepath.Join(dir, "secret.txt"), "secret")
+	if err := os.Symlink(filepath.Join(dir, "secret.txt"), filepath.Join(root, "manifests", "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	resolver, err := NewFileResolver(root)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	manifest := filepath.Join(root, "manifests", "app", "deployment.yaml")
+
+	tests := []struct {
+		name          string
+		ref           string
+		expected      string
+		expectedError string
+	}{
+		{name: "Relative to the manifest", ref: "../certs/tls.crt", expected: "-----BEGIN CERTIFICATE-----\n"},
+		{name: "Absolute path inside of the root", ref: filepath.Join(root, "manifests", "certs", "tls.crt"), expected: "-----BEGIN CERTIFICATE-----\n"},
+		{name: "Outside of the root", ref: "../../../secret.txt", expectedError: "is outside of the root directory"},
+		{name: "Absolute path outside of the root", ref: filepath.Join(dir, "secret.txt"), expectedError: "is outside of the root directory"},
+		{name: "Symlink outside of the root", ref: "../link.txt", expectedError: "escapes from parent"},
+		{name: "Missing file", ref: "missing.txt", expectedError: "file missing.txt does not exist"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			value, err := resolver.Resolve(test.ref, manifest)
+			if test.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), test.expectedError) {
+					t.Errorf("Expected error containing '%s', got %v", test.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if value != test.expected {
+				t.Errorf("Expected '%s', got '%s'", test.expected, value)
+			}
+		})
+	}
+}
+
+func TestFileResolver_Remote(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/manifests/certs/tls.crt" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, "remote-cert")
+	}))
+	defer server.Close()
+
+	resolver, err := NewFileResolver(t.TempDir())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	manifest := server.URL + "/manifests/deployment.yaml"
+
+	value, err := resolver.Resolve("certs/tls.crt", manifest)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if value != "remote-cert" {
+		t.Errorf("Expected 'remote-cert', got '%s'", value)
+	}
+
+	for _, ref := range []string{"../secret.txt", "/etc/passwd", "http://example.com/manifests/certs/tls.crt"} {
+		if _, err := resolver.Resolve(ref, manifest); err == nil || !strings.Contains(err.Error(), "is outside of the directory") {
+			t.Errorf("Expected an error for %s, got %v", ref, err)
+		}
+	}
+}
+
+func TestNewFileResolver_Errors(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file.txt")
+	writeTestFile(t, file, "")
+
+	if _, err := NewFileResolver(filepath.Join(dir, "missing")); err == nil {
+		t.Error("Expected an error for a missing root, but got none")
+	}
+	if _, err := NewFileResolver(file); err == nil {
+		t.Error("Expected an error for a root that is not a directory, but got none")
+	}
+}
+
+func writeTestFile(t *testing.T, filename, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filename, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,690 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	// tokenText is a literal text
+	tokenText tokenKind = iota
+	// tokenPlaceholder is a well-formed placeholder: $VAR, ${VAR}, ${VAR:-default}, etc...
+	tokenPlaceholder
+	// tokenMalformed is a closed placeholder with unexpected content, like ${VAR[0]},
+	// or an unterminated one, that spans the opening delimiter and the name: ${VAR
+	tokenMalformed
+	// tokenEscaped is a placeholder prefixed with an extra dollar sign: $${VAR}, $$VAR,
+	// raw includes the extra dollar sign
+	tokenEscaped
+)
+
+// position is a location of a token in the input
+type position struct {
+	filename string
+	line     int
+	column   int
+}
+
+func (pos position) String() string {
+	if pos.filename == "" {
+		return fmt.Sprintf("%d:%d", pos.line, pos.column)
+	}
+	return fmt.Sprintf("%s:%d:%d", pos.filename, pos.line, pos.column)
+}
+
+type token struct {
+	kind tokenKind
+	// raw holds the original text of a placeholder, or the literal text
+	raw    string
+	offset int
+	pos    position
+
+	name     string
+	operator string
+	// indirect is set for an indirect reference: ${!VAR}, the value of VAR is a name of a variable
+	indirect bool
+	// nameParts holds the tokens of a name with nested placeholders: ${DB_HOST_${DEPLOY_ENV}},
+	// it is nil for a plain name
+	nameParts []token
+	// word holds the tokens of an operand of an operator: ${VAR:-word}
+	word []token
+	// filters holds a pipeline of filters: ${VAR | b64enc}
+	filters []filterCall
+	// scheme and ref are set for a reference to an external value: ${file:certs/tls.crt}
+	scheme string
+	ref    string
+	// detail describes what is wrong with a malformed placeholder
+	detail string
+	// unterminated is set for a malformed placeholder without a closing delimiter
+	unterminated bool
+}
+
+// delimiters of placeholders: ${VAR} by default, or custom ones, like {{ VAR }}, @VAR@, %{VAR}
+type delimiters struct {
+	open  string
+	close string
+	// plain is set for the default delimiters, that also allow unbraced placeholders: $VAR
+	plain bool
+}
+
+var defaultDelimiters = delimiters{open: "${", close: "}", plain: true}
+
+// errUnterminated is reported for a placeholder without closing delimiter, the scanner emits
+// such placeholders as malformed tokens
+var errUnterminated = errors.New("unterminated placeholder")
+
+// delimitersDirective sets delimiters of a file in a leading comment: # kubectl-envsubst: delimiters={{,}}
+var delimitersDirective = regexp.MustCompile(`^#\s*kubectl-envsubst:\s*delimiters=(\S*)\s*$`)
+
+// parseDelimiters parses opening and closing delimiters separated by a comma: {{,}}
+func parseDelimiters(value string) (delimiters, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return delimiters{}, fmt.Errorf("invalid delimiters %q, expected opening and closing ones separated by a comma, e.g. {{,}}", value)
+	}
+	for _, part := range parts {
+		if strings.ContainsAny(part, " \t\r\n|!") || isNameChar(part[0]) || isNameChar(part[len(part)-1]) {
+			return delimiters{}, fmt.Errorf("invalid delimiter %q, it cannot contain whitespace, '|', '!', or start or end with a name character", part)
+		}
+	}
+	if parts[0] == defaultDelimiters.open && parts[1] == defaultDelimiters.close {
+		return defaultDelimiters, nil
+	}
+	return delimiters{open: parts[0], close: parts[1]}, nil
+}
+
+// findDelimitersDirective looks for a delimiters directive in leading comments of a document,
+// it returns an empty value and a zero line, when there is no directive
+func findDelimitersDirective(text string) (value string, line int) {
+	for i, l := range strings.Split(text, "\n") {
+		l = strings.TrimSpace(l)
+		if l == "" || l == "---" {
+			continue
+		}
+		if !strings.HasPrefix(l, "#") {
+			break
+		}
+		if match := delimitersDirective.FindStringSubmatch(l); match != nil {
+			return match[1], i + 1
+		}
+	}
+	return "", 0
+}
+
+// padded checks whether whitespace is allowed around the content of a placeholder: {{ VAR }}.
+// It is not allowed for the default delimiters, since ${ ...; } is a valid shell syntax.
+func (d delimiters) padded() bool {
+	return d.open != defaultDelimiters.open || d.close != defaultDelimiters.close
+}
+
+// lenient checks whether an opening delimiter without a closing one is a literal text, rather than an error.
+// A single-character delimiter is common in a regular text: user@example.com
+func (d delimiters) lenient() bool {
+	return len(d.open) == 1
+}
+
+// nestable checks whether placeholders may be nested in names: ${DB_HOST_${DEPLOY_ENV}},
+// that is ambiguous for equal delimiters: @VAR@
+func (d delimiters) nestable() bool {
+	return d.open != d.close
+}
+
+// scanner splits the input into literal text and placeholders.
+// Only well-formed placeholders are recognized, a braced placeholder must be closed on the same line,
+// otherwise it is a malformed token.
+type scanner struct {
+	input    string
+	filename string
+	delims   delimiters
+	// lineStarts holds offsets of the first character of each line
+	lineStarts []int
+}
+
+func newScanner(input, filename string) *scanner {
+	lineStarts := []int{0}
+	for i := 0; i < len(input); i++ {
+		if input[i] == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	return &scanner{
+		input:      input,
+		filename:   filename,
+		delims:     defaultDelimiters,
+		lineStarts: lineStarts,
+	}
+}
+
+// withDelimiters sets delimiters of placeholders
+func (s *scanner) withDelimiters(delims delimiters) *scanner {
+	s.delims = delims
+	return s
+}
+
+// position converts an offset into a line and a column, both are 1-based
+func (s *scanner) position(offset int) position {
+	line := sort.Search(len(s.lineStarts), func(i int) bool {
+		return s.lineStarts[i] > offset
+	})
+	return position{
+		filename: s.filename,
+		line:     line,
+		column:   offset - s.lineStarts[line-1] + 1,
+	}
+}
+
+// scanAll tokenizes the whole input
+func (s *scanner) scanAll() ([]token, error) {
+	tokens, _, err := s.scan(0, false)
+	return tokens, err
+}
+
+// scan tokenizes the input from the offset, until the end of input, or until the closing delimiter of
+// an operand, when nested is true. It returns tokens and the offset where scanning stopped.
+func (s *scanner) scan(offset int, nested bool) ([]token, int, error) {
+	tokens := []token{}
+	textStart := offset
+
+	flush := func(end int) {
+		if end > textStart {
+			tokens = append(tokens, token{kind: tokenText, raw: s.input[textStart:end], offset: textStart, pos: s.position(textStart)})
+		}
+	}
+
+	i := offset
+	for i < len(s.input) {
+		c := s.input[i]
+
+		// a pipeline of filters starts at '|' after whitespace, so operands may contain it: ${VAR:-a|b}
+		if nested && ((c == '|' && i > 0 && isSpaceByte(s.input[i-1])) || c == '\n' || s.hasPrefix(i, s.delims.close)) {
+			break
+		}
+		if c != s.delims.open[0] {
+			i++
+			continue
+		}
+
+		// escaped placeholder: $${VAR} or $$VAR, for custom delimiters the first character
+		// of the opening delimiter is doubled: {{{ VAR }}, @@VAR@
+		// an unterminated one is not escaped, it is reported as usual: $${VAR
+		if t, end, ok, err := s.scanAt(i + 1); err != nil {
+			return nil, i, err
+		} else if ok && !t.unterminated {
+			flush(i)
+			t.kind = tokenEscaped
+			t.raw = s.input[i:end]
+			t.offset = i
+			t.pos = s.position(i)
+			tokens = append(tokens, t)
+			i, textStart = end, end
+			continue
+		}
+
+		if t, end, ok, err := s.scanAt(i); err != nil {
+			return nil, i, err
+		} else if ok {
+			flush(i)
+			tokens = append(tokens, t)
+			i, textStart = end, end
+			continue
+		}
+
+		i++
+	}
+
+	flush(i)
+	return tokens, i, nil
+}
+
+// scanAt scans a placeholder at the offset, ok is false when there is no placeholder.
+// An unterminated placeholder is a malformed token, so it is an error for allowed variables only,
+// like a JavaScript template literal, that spans multiple lines: ${items.map(i =>
+// With lenient delimiters, an unterminated or malformed placeholder is a literal text.
+func (s *scanner) scanAt(offset int) (t token, end int, ok bool, err error) {
+	if !s.isPlaceholderStart(offset) {
+		return t, offset, false, nil
+	}
+	t, end, err = s.scanPlaceholder(offset)
+	if s.delims.lenient() && (errors.Is(err, errUnterminated) || (err == nil && t.kind == tokenMalformed)) {
+		return t, offset, false, nil
+	}
+	if errors.Is(err, errUnterminated) {
+		t, end = s.unterminatedToken(offset)
+		return t, end, true, nil
+	}
+	return t, end, err == nil, err
+}
+
+// hasPrefix checks whether the input at the offset starts with the prefix
+func (s *scanner) hasPrefix(offset int, prefix string) bool {
+	return strings.HasPrefix(s.input[offset:], prefix)
+}
+
+// isPlaceholderStart checks whether a placeholder starts at the offset: $VAR or ${VAR
+func (s *scanner) isPlaceholderStart(offset int) bool {
+	if offset >= len(s.input) {
+		return false
+	}
+	if s.hasPrefix(offset, s.delims.open) {
+		nameStart := s.skipPadding(offset + len(s.delims.open))
+		if nameStart < len(s.input) && s.input[nameStart] == '!' {
+			nameStart++
+		}
+		return nameStart < len(s.input) && (isNameStart(s.input[nameStart]) || (s.delims.nestable() && s.isBracedStart(nameStart)))
+	}
+	// unbraced placeholder: $VAR
+	return s.delims.plain && offset+1 < len(s.input) && s.input[offset] == '$' && isNameStart(s.input[offset+1])
+}
+
+// isBracedStart checks whether a braced placeholder starts at the offset: ${VAR
+func (s *scanner) isBracedStart(offset int) bool {
+	return s.hasPrefix(offset, s.delims.open) && s.isPlaceholderStart(offset)
+}
+
+// skipPadding returns the offset after whitespace, that is allowed inside of custom delimiters
+func (s *scanner) skipPadding(offset int) int {
+	if !s.delims.padded() {
+		return offset
+	}
+	for offset < len(s.input) && (s.input[offset] == ' ' || s.input[offset] == '\t') {
+		offset++
+	}
+	return offset
+}
+
+// scanPlaceholder scans a placeholder that starts at the offset, it returns the offset after the placeholder
+func (s *scanner) scanPlaceholder(offset int) (token, int, error) {
+	t := token{kind: tokenPlaceholder, offset: offset, pos: s.position(offset)}
+
+	// plain placeholder: $VAR
+	if !s.hasPrefix(offset, s.delims.open) {
+		end := s.scanName(offset + 1)
+		t.name = s.input[offset+1 : end]
+		t.raw = s.input[offset:end]
+		return t, end, nil
+	}
+
+	// braced placeholder: ${VAR}, ${VAR:-default}, ${!VAR}, ${VAR_${INNER}}, etc...
+	closeLen := len(s.delims.close)
+	nameStart := s.skipPadding(offset + len(s.delims.open))
+	if s.input[nameStart] == '!' {
+		t.indirect = true
+		nameStart++
+	}
+	parts, nameEnd, err := s.scanNameParts(nameStart)
+	if err != nil {
+		return t, nameEnd, err
+	}
+	t.name = s.input[nameStart:nameEnd]
+	if len(parts) > 1 || parts[0].kind != tokenText {
+		t.nameParts = parts
+	}
+	end := nameEnd
+
+	// reference to an external value: ${file:certs/tls.crt}
+	if !t.indirect && t.nameParts == nil && referenceSchemes[t.name] && s.isReferenceStart(nameEnd) {
+		return s.scanReference(t, offset, nameEnd)
+	}
+
+	// a malformed nested placeholder makes the whole placeholder malformed
+	if detail := malformedDetail(parts); detail != "" {
+		closing := s.scanLineClose(nameEnd)
+		if closing < 0 {
+			return t, nameEnd, s.unterminated(offset)
+		}
+		t.kind = tokenMalformed
+		t.detail = detail
+		t.raw = s.input[offset : closing+closeLen]
+		return t, closing + closeLen, nil
+	}
+
+	if operator := scanOperator(s.input[nameEnd:]); operator != "" {
+		word, wordEnd, err := s.scan(nameEnd+len(operator), true)
+		if err != nil {
+			return t, wordEnd, err
+		}
+		if wordEnd >= len(s.input) || s.input[wordEnd] == '\n' {
+			return t, wordEnd, s.unterminated(offset)
+		}
+		t.operator = operator
+		t.word = word
+		if s.delims.padded() {
+			t.word = trimTrailingSpaces(t.word)
+		}
+		end = wordEnd
+	}
+
+	// pipeline of filters: ${VAR | b64enc | quote}
+	pipeStart := end
+	for pipeStart < len(s.input) && (s.input[pipeStart] == ' ' || s.input[pipeStart] == '\t') {
+		pipeStart++
+	}
+	if pipeStart < len(s.input) && s.input[pipeStart] == '|' {
+		closing := s.scanClosingDelimiter(pipeStart)
+		if closing < 0 {
+			return t, pipeStart, s.unterminated(offset)
+		}
+		t.raw = s.input[offset : closing+closeLen]
+		calls, err := parseFilters(s.input[pipeStart+1 : closing])
+		if err != nil {
+			t.kind = tokenMalformed
+			t.detail = err.Error()
+			return t, closing + closeLen, nil
+		}
+		t.filters = calls
+		t.word = trimTrailingSpaces(t.word)
+		return t, closing + closeLen, nil
+	}
+
+	if t.operator != "" {
+		t.raw = s.input[offset : end+closeLen]
+		return t, end + closeLen, nil
+	}
+
+	// look for the closing delimiter on the same line
+	closing := s.scanLineClose(nameEnd)
+	if closing < 0 {
+		return t, nameEnd, s.unterminated(offset)
+	}
+
+	t.raw = s.input[offset : closing+closeLen]
+	if contentEnd := s.skipPadding(nameEnd); closing != contentEnd {
+		t.kind = tokenMalformed
+		t.detail = fmt.Sprintf("unexpected character %q", s.input[contentEnd])
+	}
+	return t, closing + closeLen, nil
+}
+
+// isReferenceStart checks whether a reference follows a scheme at the offset: ${file:path},
+// so that operators of variables with the same name remain possible: ${file:-default}, ${file: -3}
+func (s *scanner) isReferenceStart(offset int) bool {
+	if offset+1 >= len(s.input) || s.input[offset] != ':' {
+		return false
+	}
+	return !strings.ContainsRune("-=+?| \t\n", rune(s.input[offset+1])) && !s.hasPrefix(offset+1, s.delims.close)
+}
+
+// scanReference scans the rest of a reference, that starts with a scheme at the offset:
+// ${file:certs/tls.crt}, the reference spans to a pipeline of filters, or to the closing delimiter
+func (s *scanner) scanReference(t token, offset, schemeEnd int) (token, int, error) {
+	closeLen := len(s.delims.close)
+	refEnd := schemeEnd + 1
+	for refEnd < len(s.input) && s.input[refEnd] != '\n' && s.input[refEnd] != '|' && !s.hasPrefix(refEnd, s.delims.close) {
+		refEnd++
+	}
+	if refEnd >= len(s.input) || s.input[refEnd] == '\n' {
+		return t, refEnd, s.unterminated(offset)
+	}
+	t.scheme = t.name
+	t.ref = strings.TrimSpace(s.input[schemeEnd+1 : refEnd])
+	// filter lists check the normalized name, so a path cannot escape a prefix: file:certs/../secret.txt
+	if ref, err := normalizeReference(t.scheme, t.ref); err != nil {
+		t.kind = tokenMalformed
+		t.detail = err.Error()
+	} else {
+		t.ref = ref
+	}
+	t.name = t.scheme + ":" + t.ref
+
+	closing := refEnd
+	if s.input[refEnd] == '|' {
+		closing = s.scanClosingDelimiter(refEnd)
+		if closing < 0 {
+			return t, refEnd, s.unterminated(offset)
+		}
+		calls, err := parseFilters(s.input[refEnd+1 : closing])
+		if err != nil {
+			t.kind = tokenMalformed
+			t.detail = err.Error()
+		}
+		t.filters = calls
+	}
+	t.raw = s.input[offset : closing+closeLen]
+	return t, closing + closeLen, nil
+}
+
+// scanClosingDelimiter returns the offset of a closing delimiter of a pipeline on the same line,
+// that is not inside of double quotes, or -1 if there is no such delimiter
+func (s *scanner) scanClosingDelimiter(offset int) int {
+	quoted := false
+	for i := offset; i < len(s.input) && s.input[i] != '\n'; i++ {
+		switch {
+		case s.input[i] == '\\':
+			i++
+		case s.input[i] == '"':
+			quoted = !quoted
+		case !quoted && s.hasPrefix(i, s.delims.close):
+			return i
+		}
+	}
+	return -1
+}
+
+// scanLineClose returns the offset of the first closing delimiter on the same line, or -1 if there is no such delimiter
+func (s *scanner) scanLineClose(offset int) int {
+	for i := offset; i < len(s.input) && s.input[i] != '\n'; i++ {
+		if s.hasPrefix(i, s.delims.close) {
+			return i
+		}
+	}
+	return -1
+}
+
+// scanNameParts scans a name of a braced placeholder, that may be a path: db.primary.host, images[0].tag,
+// and may contain nested braced placeholders: DB_HOST_${DEPLOY_ENV}.
+// It returns literal parts and placeholders, and the offset after the name.
+func (s *scanner) scanNameParts(offset int) ([]token, int, error) {
+	parts := []token{}
+	textStart := offset
+	flush := func(end int) {
+		if end > textStart {
+			parts = append(parts, token{kind: tokenText, raw: s.input[textStart:end], offset: textStart, pos: s.position(textStart)})
+		}
+	}
+
+	i := offset
+	for i < len(s.input) {
+		if isNameChar(s.input[i]) {
+			i++
+			continue
+		}
+		// path segments: .key or [index], not with single-character delimiters: user@example.com
+		if i > offset && !s.delims.lenient() && s.input[i] == '.' && i+1 < len(s.input) &&
+			(isNameStart(s.input[i+1]) || (s.delims.nestable() && s.isBracedStart(i+1))) {
+			i++
+			continue
+		}
+		if i > offset && !s.delims.lenient() && s.input[i] == '[' {
+			if end := s.scanIndex(i); end > 0 {
+				i = end
+				continue
+			}
+		}
+		if !s.delims.nestable() || !s.isBracedStart(i) {
+			break
+		}
+		t, end, err := s.scanPlaceholder(i)
+		if err != nil {
+			return nil, end, err
+		}
+		flush(i)
+		parts = append(parts, t)
+		i, textStart = end, end
+	}
+
+	flush(i)
+	return parts, i, nil
+}
+
+// scanIndex returns the offset after an index of a path: [0], or -1 if there is no index at the offset
+func (s *scanner) scanIndex(offset int) int {
+	i := offset + 1
+	for i < len(s.input) && s.input[i] >= '0' && s.input[i] <= '9' {
+		i++
+	}
+	if i == offset+1 || i >= len(s.input) || s.input[i] != ']' {
+		return -1
+	}
+	return i + 1
+}
+
+// malformedDetail returns the description of the first malformed token, or an empty string
+func malformedDetail(tokens []token) string {
+	for i := range tokens {
+		if tokens[i].kind == tokenMalformed {
+			return tokens[i].detail
+		}
+	}
+	return ""
+}
+
+// scanName returns the offset after a variable name that starts at the offset
+func (s *scanner) scanName(offset int) int {
+	end := offset
+	for end < len(s.input) && isNameChar(s.input[end]) {
+		end++
+	}
+	return end
+}
+
+// unterminated reports a braced placeholder without a closing delimiter
+func (s *scanner) unterminated(offset int) error {
+	nameStart := s.skipPadding(offset + len(s.delims.open))
+	if nameStart < len(s.input) && s.input[nameStart] == '!' {
+		nameStart++
+	}
+	return fmt.Errorf("%s: %w %s, missing '%s'", s.position(offset), errUnterminated, s.input[offset:s.scanName(nameStart)], s.delims.close)
+}
+
+// unterminatedToken returns a malformed token of a braced placeholder without a closing delimiter,
+// that spans the opening delimiter and the name: ${APP_IMAGE, the rest of the line is scanned as usual
+func (s *scanner) unterminatedToken(offset int) (token, int) {
+	nameStart := s.skipPadding(offset + len(s.delims.open))
+	if nameStart < len(s.input) && s.input[nameStart] == '!' {
+		nameStart++
+	}
+	nameEnd := s.scanName(nameStart)
+	t := token{
+		kind:         tokenMalformed,
+		raw:          s.input[offset:nameEnd],
+		offset:       offset,
+		pos:          s.position(offset),
+		name:         s.input[nameStart:nameEnd],
+		detail:       fmt.Sprintf("missing '%s'", s.delims.close),
+		unterminated: true,
+	}
+	if referenceSchemes[t.name] && s.isReferenceStart(nameEnd) {
+		t.scheme = t.name
+	}
+	return t, nameEnd
+}
+
+// referenceSchemes holds schemes of references to external values: ${file:certs/tls.crt}
+var referenceSchemes = map[string]bool{
+	"file":  true,
+	"exec":  true,
+	"vault": true,
+	// ${ENVSUBST_HASH:configmap.yaml}, while ${ENVSUBST_HASH} is a built-in variable
+	"ENVSUBST_HASH": true,
+}
+
+// operators in the order of matching, longer ones first
+var operators = []string{
+	// POSIX operators
+	":-", ":=", ":+", ":?", "-", "=", "+", "?",
+	// string manipulation operators
+	"^^", "^", ",,", ",", ":", "##", "#", "%%", "%", "//", "/#", "/%", "/",
+}
+
+// scanOperator returns an operator at the beginning of the text, if any
+func scanOperator(text string) string {
+	for _, operator := range operators {
+		if strings.HasPrefix(text, operator) {
+			return operator
+		}
+	}
+	return ""
+}
+
+// isManipulation checks whether the operator is a string manipulation one
+func isManipulation(operator string) bool {
+	switch operator {
+	case "^^", "^", ",,", ",", ":", "##", "#", "%%", "%", "//", "/#", "/%", "/":
+		return true
+	}
+	return false
+}
+
+// splitTokens splits tokens at the first separator in a literal text, that is not escaped with a backslash
+func splitTokens(tokens []token, sep byte) (before, after []token) {
+	for i := range tokens {
+		t := tokens[i]
+		if t.kind != tokenText {
+			continue
+		}
+		if idx := indexUnescaped(t.raw, sep); idx >= 0 {
+			before = append(before, tokens[:i]...)
+			before = append(before, token{kind: tokenText, raw: t.raw[:idx], offset: t.offset, pos: t.pos})
+			after = append(after, token{kind: tokenText, raw: t.raw[idx+1:], offset: t.offset + idx + 1, pos: t.pos})
+			after = append(after, tokens[i+1:]...)
+			return before, after
+		}
+	}
+	return tokens, nil
+}
+
+// trimTrailingSpaces removes whitespace at the end of the last literal text token
+func trimTrailingSpaces(tokens []token) []token {
+	if len(tokens) == 0 || tokens[len(tokens)-1].kind != tokenText {
+		return tokens
+	}
+	last := tokens[len(tokens)-1]
+	last.raw = strings.TrimRight(last.raw, " \t")
+	return append(tokens[:len(tokens)-1:len(tokens)-1], last)
+}
+
+// indexUnescaped returns the index of the first separator, that is not escaped with a backslash
+func indexUnescaped(s string, sep byte) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case sep:
+			return i
+		}
+	}
+	return -1
+}
+
+// isName checks whether the text is a valid name of a variable
+func isName(text string) bool {
+	if text == "" || !isNameStart(text[0]) {
+		return false
+	}
+	for i := 1; i < len(text); i++ {
+		if !isNameChar(text[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// isPath checks whether the text is a valid name of a variable, or a path: db.primary.host, images[0].tag
+func isPath(text string) bool {
+	s := newScanner(text, "")
+	parts, end, err := s.scanNameParts(0)
+	return err == nil && end == len(text) && len(parts) == 1 && parts[0].kind == tokenText && isNameStart(text[0])
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isNameChar(c byte) bool {
+	return isNameStart(c) || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,305 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestScanner(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected []string
+	}{
+		{"$VAR", []string{"VAR"}},
+		{"${VAR}", []string{"VAR"}},
+		{"$1VAR", nil},
+		{"$(VAR)", nil},
+		{"${VAR1_2}", []string{"VAR1_2"}},
+		{"No variable here", nil},
+		{"$VAR}", []string{"VAR"}},
+		{"${VAR:-default}", []string{"VAR"}},
+		{"${VAR:-${OTHER}}", []string{"VAR"}},
+		{"$$VAR $${VAR}", nil},
+		{"${}", nil},
+		{"${123VAR}", nil},
+		{"$A$B", []string{"A", "B"}},
+		{"${!VAR}", []string{"VAR"}},
+		{"${VAR_${INNER}}", []string{"VAR_${INNER}"}},
+		{"${${INNER}_VAR:-x}", []string{"${INNER}_VAR"}},
+		{"${!}", nil},
+		{"${file:certs/tls.crt}", []string{"file:certs/tls.crt"}},
+		{"${file:tls.crt | b64enc}", []string{"file:tls.crt"}},
+		{"${file:-default} ${file: -3}", []string{"file", "file"}},
+		{"$file:tls.crt", []string{"file"}},
+	}
+
+	for _, tc := range testCases {
+		tokens, err := newScanner(tc.input, "").scanAll()
+		if err != nil {
+			t.Errorf("For input '%s', unexpected error: %v", tc.input, err)
+			continue
+		}
+		var result []string
+		for _, token := range tokens {
+			if token.kind == tokenPlaceholder {
+				result = append(result, token.name)
+			}
+		}
+
+		if len(result) != len(tc.expected) {
+			t.Errorf("For input '%s', expected %v, got %v", tc.input, tc.expected, result)
+			continue
+		}
+		for i, v := range result {
+			if v != tc.expected[i] {
+				t.Errorf("For input '%s', expected %v, got %v", tc.input, tc.expected, result)
+				break
+			}
+		}
+	}
+}
+
+func TestScanner_Positions(t *testing.T) {
+	input := "kind: ConfigMap\nmetadata:\n  name: ${APP_NAME}\n  labels: {app: $APP_NAME}\n"
+
+	tokens, err := newScanner(input, "cm.yaml").scanAll()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []string{"cm.yaml:3:9", "cm.yaml:4:17"}
+	result := []string{}
+	for _, token := range tokens {
+		if token.kind == tokenPlaceholder {
+			result = append(result, token.pos.String())
+		}
+	}
+	if strings.Join(result, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected positions %v, got %v", expected, result)
+	}
+}
+
+func TestScanner_SyntaxErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		input         string
+		expectedError string
+	}{
+		{
+			name:          "Unterminated placeholder",
+			input:         "name: ${APP_NAME",
+			expectedError: "cm.yaml:1:7: unterminated placeholder ${APP_NAME, missing '}'",
+		},
+		{
+			name:          "Closing brace on the next line",
+			input:         "kind: ConfigMap\nname: ${APP_NAME\n}",
+			expectedError: "cm.yaml:2:7: unterminated placeholder ${APP_NAME, missing '}'",
+		},
+		{
+			name:          "Unterminated operator",
+			input:         "image: ${APP_IMAGE:-nginx",
+			expectedError: "cm.yaml:1:8: unterminated placeholder ${APP_IMAGE, missing '}'",
+		},
+		{
+			name:          "Unterminated nested placeholder",
+			input:         "image: ${APP_IMAGE:-${APP_DEFAULT}",
+			expectedError: "cm.yaml:1:8: unterminated placeholder ${APP_IMAGE, missing '}'",
+		},
+		{
+			name:          "Unterminated reference",
+			input:         "cert: ${file:tls.crt",
+			expectedError: "cm.yaml:1:7: unterminated placeholder ${file, missing '}'",
+		},
+		{
+			name:          "Unterminated escaped placeholder",
+			input:         "  $${PATH",
+			expectedError: "cm.yaml:1:4: unterminated placeholder ${PATH, missing '}'",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tokens, err := newScanner(test.input, "cm.yaml").scanAll()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			err = firstMalformedError(tokens)
+			if err == nil {
+				t.Fatal("Expected a syntax error, but got none")
+			}
+			if err.Error() != test.expectedError {
+				t.Errorf("Expected error '%s', got '%s'", test.expectedError, err.Error())
+			}
+		})
+	}
+}
+
+func TestScanner_UnterminatedTokens(t *testing.T) {
+	input := "a: ${OTHER $APP_NAME\nscript: `${items.map(i =>\n  i.name)}`"
+	tokens, err := newScanner(input, "cm.yaml").scanAll()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var result []string
+	for _, token := range tokens {
+		switch token.kind {
+		case tokenMalformed:
+			result = append(result, "malformed "+token.raw)
+		case tokenPlaceholder:
+			result = append(result, token.raw)
+		}
+	}
+	expected := []string{"malformed ${OTHER", "$APP_NAME", "malformed ${items"}
+	if strings.Join(result, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected tokens %v, got %v", expected, result)
+	}
+}
+
+// firstMalformedError returns the error of the first malformed token, or nil
+func firstMalformedError(tokens []token) error {
+	for i := range tokens {
+		if tokens[i].kind == tokenMalformed {
+			return malformedError(&tokens[i])
+		}
+	}
+	return nil
+}
+
+func TestScanner_References(t *testing.T) {
+	tokens, err := newScanner("${file:certs/tls.crt | b64enc}", "").scanAll()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(tokens) != 1 {
+		t.Fatalf("Expected 1 token, got %d", len(tokens))
+	}
+	ref := tokens[0]
+	if ref.kind != tokenPlaceholder || ref.scheme != "file" || ref.ref != "certs/tls.crt" || len(ref.filters) != 1 {
+		t.Errorf("Expected a reference to certs/tls.crt with a filter, got %+v", ref)
+	}
+
+	tokens, err = newScanner("{{ file:ca.crt }}", "").withDelimiters(delimiters{open: "{{", close: "}}"}).scanAll()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(tokens) != 1 || tokens[0].scheme != "file" || tokens[0].ref != "ca.crt" {
+		t.Errorf("Expected a reference to ca.crt, got %+v", tokens)
+	}
+}
+
+func TestParseDelimiters(t *testing.T) {
+	tests := []struct {
+		value       string
+		expected    delimiters
+		expectedErr bool
+	}{
+		{value: "${,}", expected: defaultDelimiters},
+		{value: "{{,}}", expected: delimiters{open: "{{", close: "}}"}},
+		{value: "@,@", expected: delimiters{open: "@", close: "@"}},
+		{value: "%{,}", expected: delimiters{open: "%{", close: "}"}},
+		{value: "", expectedErr: true},
+		{value: "{{", expectedErr: true},
+		{value: "{{,", expectedErr: true},
+		{value: "{{,}},", expectedErr: true},
+		{value: "{ ,}", expectedErr: true},
+		{value: "a{,}", expectedErr: true},
+		{value: "{,}a", expectedErr: true},
+		{value: "|,|", expectedErr: true},
+	}
+
+	for _, test := range tests {
+		result, err := parseDelimiters(test.value)
+		if (err != nil) != test.expectedErr {
+			t.Errorf("For %q, expected error: %v, got: %v", test.value, test.expectedErr, err)
+			continue
+		}
+		if result != test.expected {
+			t.Errorf("For %q, expected %+v, got %+v", test.value, test.expected, result)
+		}
+	}
+}
+
+func TestFindDelimitersDirective(t *testing.T) {
+	tests := []struct {
+		text          string
+		expectedValue string
+		expectedLine  int
+	}{
+		{text: "# kubectl-envsubst: delimiters={{,}}\nkind: ConfigMap", expectedValue: "{{,}}", expectedLine: 1},
+		{text: "---\n# license\n\n#kubectl-envsubst:delimiters=@,@\nkind: ConfigMap", expectedValue: "@,@", expectedLine: 4},
+		{text: "kind: ConfigMap\n# kubectl-envsubst: delimiters={{,}}", expectedValue: "", expectedLine: 0},
+		{text: "# kubectl-envsubst: delimiters=", expectedValue: "", expectedLine: 1},
+		{text: "", expectedValue: "", expectedLine: 0},
+	}
+
+	for _, test := range tests {
+		value, line := findDelimitersDirective(test.text)
+		if value != test.expectedValue || line != test.expectedLine {
+			t.Errorf("For %q, expected (%q, %d), got (%q, %d)", test.text, test.expectedValue, test.expectedLine, value, line)
+		}
+	}
+}
+
+func TestScanner_Delimiters(t *testing.T) {
+	testCases := []struct {
+		delims   string
+		input    string
+		expected []string
+	}{
+		{"{{,}}", "{{ VAR }} {{VAR}} {{ VAR:-x }} {{ VAR | quote }}", []string{"VAR", "VAR", "VAR", "VAR"}},
+		{"{{,}}", "${VAR} $VAR {{ .Values.image }} {{- if .Values.x }}", nil},
+		{"{{,}}", "{{{ VAR }} {{ A_{{ B }} }}", []string{"A_{{ B }}"}},
+		{"@,@", "@VAR@ @@VAR@ user@example.com @ @VAR", []string{"VAR"}},
+		{"@,@", "@VAR@ @A_@B@", []string{"VAR", "A_"}},
+		{"%{,}", "%{VAR} %%{VAR} 100% %{ VAR }", []string{"VAR", "VAR"}},
+	}
+
+	for _, tc := range testCases {
+		delims, err := parseDelimiters(tc.delims)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		tokens, err := newScanner(tc.input, "").withDelimiters(delims).scanAll()
+		if err != nil {
+			t.Errorf("For input '%s', unexpected error: %v", tc.input, err)
+			continue
+		}
+		var result []string
+		for _, token := range tokens {
+			if token.kind == tokenPlaceholder {
+				result = append(result, token.name)
+			}
+		}
+		if strings.Join(result, ",") != strings.Join(tc.expected, ",") {
+			t.Errorf("For input '%s' with delimiters %s, expected %v, got %v", tc.input, tc.delims, tc.expected, result)
+		}
+	}
+}
+
+func TestScanner_Delimiters_SyntaxErrors(t *testing.T) {
+	tests := []struct {
+		delims        string
+		input         string
+		expectedError string
+	}{
+		{delims: "{{,}}", input: "name: {{ APP_NAME\n", expectedError: "cm.yaml:1:7: unterminated placeholder {{ APP_NAME, missing '}}'"},
+		{delims: "{{,}}", input: "name: {{ APP_NAME:-x\n", expectedError: "cm.yaml:1:7: unterminated placeholder {{ APP_NAME, missing '}}'"},
+		{delims: "%{,}", input: "name: %{APP_NAME | quote", expectedError: "cm.yaml:1:7: unterminated placeholder %{APP_NAME, missing '}'"},
+	}
+
+	for _, test := range tests {
+		delims, err := parseDelimiters(test.delims)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		tokens, err := newScanner(test.input, "cm.yaml").withDelimiters(delims).scanAll()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		err = firstMalformedError(tokens)
+		if err == nil || err.Error() != test.expectedError {
+			t.Errorf("For input '%s', expected error '%s', got '%v'", test.input, test.expectedError, err)
+		}
+	}
+}
@@ -1,63 +1,133 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
-	"regexp"
+	"path/filepath"
 	"sort"
 	"strings"
 )
 
-// Match placeholders like ${VAR} or $VAR
-var envVarRegex = regexp.MustCompile(`\$\{?([a-zA-Z_][a-zA-Z0-9_]*)\}?`)
+// Policies of placeholders of denied variables
+const (
+	// DeniedPolicyIgnore leaves placeholders of denied variables unchanged
+	DeniedPolicyIgnore = "ignore"
+	// DeniedPolicyError fails the substitution, when a document has placeholders of denied variables
+	DeniedPolicyError = "error"
+)
+
+// Strictness levels, that choose unresolved placeholders, that are errors
+const (
+	// StrictOff never fails on unresolved placeholders
+	StrictOff = "off"
+	// StrictFiltered fails on unresolved placeholders of variables of the allowed lists
+	StrictFiltered = "filtered"
+	// StrictAll fails on any placeholder, that remains in the document
+	StrictAll = "all"
+)
+
+// maxExpansionDepth limits nesting of placeholders in names of variables: ${DB_HOST_${DEPLOY_ENV}}
+const maxExpansionDepth = 16
+
+// substitution holds the state of a single SubstituteEnvs call
+type substitution struct {
+	envMap     map[string]string
+	unresolved []token
+	// errs holds failures of placeholders, that are reported together with unresolved ones
+	errs []error
+	// depth is the nesting level of names that are being resolved
+	depth int
+	// references holds variables of indirect references that are being resolved, to detect cycles
+	references []string
+	// delims holds delimiters of the document, that are also used in values of indirect references
+	delims delimiters
+}
 
 type Envsubst struct {
 	allowedVars     []string
 	allowedPrefixes []string
-	strict          bool
+	allowedPatterns []namePattern
+	strict          string
 	verbose         bool
+	filename        string
+	delimiters      delimiters
+	bracesOnly      bool
+	source          VarSource
+	// deniedVars and deniedPrefixes take precedence over allowed lists and built-ins
+	deniedVars     []string
+	deniedPrefixes []string
+	deniedPolicy   string
+	// resolvers holds resolvers of references by their schemes: ${file:certs/tls.crt}
+	resolvers map[string]Resolver
+	// builtins holds built-in variables, that are allowed regardless of filter lists: ENVSUBST_GIT_SHA
+	builtins map[string]string
+	// explanations holds outcomes of placeholders of the last document in explain mode
+	explain       bool
+	explainValues bool
+	explanations  []Explanation
 }
 
-func NewEnvsubst(allowedVars, allowedPrefixes []string, strict bool) *Envsubst {
+// NewEnvsubst creates a substitution engine, that takes values from sources, the first source that
+// has a variable wins. The process environment is used, when no sources are given.
+func NewEnvsubst(allowedVars, allowedPrefixes []string, strict bool, sources ...VarSource) *Envsubst {
+	var source VarSource = EnvSource{}
+	switch len(sources) {
+	case 0:
+	case 1:
+		source = sources[0]
+	default:
+		source = ChainSource(sources)
+	}
 	return &Envsubst{
 		allowedVars:     allowedVars,
 		allowedPrefixes: allowedPrefixes,
-		strict:          strict,
+		strict:          strictLevel(strict),
+		delimiters:      defaultDelimiters,
+		source:          source,
+		deniedPolicy:    DeniedPolicyIgnore,
 	}
 }
 
 func (p *Envsubst) SubstituteEnvs(text string) (string, error) {
-	// Collect allowed environment variables
-	envMap := p.collectAllowedEnvVars()
-
-	// Perform substitution using regex
-	substituted := envVarRegex.ReplaceAllStringFunc(text, func(match string) string {
-		// Extract the variable name
-		// alternate: varName := envVarRegex.FindStringSubmatch(match)[1]
-		varName := strings.Trim(match, "${}")
+	// Split the input into literal text and placeholders
+	delims, err := p.delimitersFor(text)
+	if err != nil {
+		return "", err
+	}
+	tokens, err := newScanner(text, p.filename).withDelimiters(delims).scanAll()
+	if err != nil {
+		return "", err
+	}
 
-		// get value, according to filters
-		if value, ok := envMap[varName]; ok {
-			return value
-		}
+	// Collect allowed environment variables
+	p.explanations = nil
+	state := &substitution{
+		envMap: p.collectAllowedEnvVars(),
+		delims: delims,
+	}
 
-		return match
-	})
+	// Perform substitution
+	// Unresolved placeholders are collected from the input, so escaped placeholders
+	// and values that look like placeholders are never reported
+	substituted := p.render(text, tokens, state)
+	p.sortExplanations()
 
 	// Handle unresolved variables in strict mode
 	// Returns error, if and only if an unresolved variable is from one of the filter-list.
 	// Ignoring other unexpanded variables, that may be a parts of config-maps, etc...
 	//
-	if err := p.checkUnresolvedStrictMode(substituted); err != nil {
+	// Failures are reported with a single error, that lists failed placeholders, every variable and its locations
+	errs := append(state.errs, p.checkUnresolvedStrictMode(state.unresolved), p.checkDenied(state.unresolved))
+	if err := errors.Join(errs...); err != nil {
 		return "", err
 	}
 
 	// Log unresolved variables in verbose mode
 	// if there are unexpanded placeholders, it's not an error, just debug-info
 	// it's not an error, because these placeholders are not in filter lists, so they remain unchanged
-	unresolved := envVarRegex.FindAllString(substituted, -1)
-	p.logUnresolvedVariables(unresolved)
+	p.logUnresolvedVariables(tokenNames(state.unresolved))
 
 	return substituted, nil
 }
@@ -66,29 +136,555 @@ func (p *Envsubst) SetVerbose(value bool) {
 	p.verbose = value
 }
 
+// SetFilename sets the name of the processed file, that is reported in errors
+func (p *Envsubst) SetFilename(value string) {
+	p.filename = value
+}
+
+// SetDelimiters sets delimiters of placeholders, separated by a comma: {{,}}, @,@, %{,}
+func (p *Envsubst) SetDelimiters(value string) error {
+	delims, err := parseDelimiters(value)
+	if err != nil {
+		return err
+	}
+	p.delimiters = delims
+	return nil
+}
+
+// SetAllowedPatterns sets patterns of allowed variables: globs (*_IMAGE), regular expressions (/^CI_/),
+// and negated patterns (!APP_DEBUG), that exclude names from other patterns
+func (p *Envsubst) SetAllowedPatterns(patterns []string) error {
+	parsed, err := parsePatterns(patterns)
+	if err != nil {
+		return err
+	}
+	p.allowedPatterns = parsed
+	return nil
+}
+
+// SetDenied sets variables and prefixes of variables, that are never substituted,
+// they take precedence over allowed lists, patterns and built-in variables
+func (p *Envsubst) SetDenied(vars, prefixes []string) {
+	p.deniedVars = vars
+	p.deniedPrefixes = prefixes
+}
+
+// SetDeniedPolicy sets the handling of placeholders of denied variables:
+// DeniedPolicyIgnore leaves them unchanged, DeniedPolicyError fails the substitution
+func (p *Envsubst) SetDeniedPolicy(value string) error {
+	if value != DeniedPolicyIgnore && value != DeniedPolicyError {
+		return fmt.Errorf("invalid denied policy %q, expected %s or %s", value, DeniedPolicyIgnore, DeniedPolicyError)
+	}
+	p.deniedPolicy = value
+	return nil
+}
+
+// SetStrict sets the strictness level: StrictOff, StrictFiltered or StrictAll
+func (p *Envsubst) SetStrict(level string) error {
+	if level != StrictOff && level != StrictFiltered && level != StrictAll {
+		return fmt.Errorf("invalid strict level %q, expected %s, %s or %s", level, StrictOff, StrictFiltered, StrictAll)
+	}
+	p.strict = level
+	return nil
+}
+
+// SetBracesOnly enables the mode, in which unbraced placeholders ($VAR) are ignored entirely
+func (p *Envsubst) SetBracesOnly(value bool) {
+	p.bracesOnly = value
+}
+
+// SetResolver enables references of a scheme: ${file:certs/tls.crt}, references of schemes
+// without a resolver remain unchanged
+func (p *Envsubst) SetResolver(scheme string, resolver Resolver) {
+	if p.resolvers == nil {
+		p.resolvers = make(map[string]Resolver)
+	}
+	p.resolvers[scheme] = resolver
+}
+
+// AddBuiltins adds built-in variables, that are allowed regardless of filter lists,
+// variables of the source with the same names take precedence over them
+func (p *Envsubst) AddBuiltins(vars map[string]string) {
+	if p.builtins == nil {
+		p.builtins = make(map[string]string)
+	}
+	for name, value := range vars {
+		p.builtins[name] = value
+	}
+}
+
 // Helper Functions
 
+// delimitersFor returns delimiters of a document, a directive in its leading comments
+// takes precedence over delimiters of the run
+func (p *Envsubst) delimitersFor(text string) (delimiters, error) {
+	delims := p.delimiters
+	if value, line := findDelimitersDirective(text); line != 0 {
+		var err error
+		delims, err = parseDelimiters(value)
+		if err != nil {
+			return delimiters{}, fmt.Errorf("%s: %w", position{filename: p.filename, line: line, column: 1}, err)
+		}
+	}
+	if p.bracesOnly {
+		delims.plain = false
+	}
+	return delims, nil
+}
+
+// render substitutes placeholders of a document, values are escaped according to their context,
+// failed placeholders remain unchanged, their errors are collected in the state
+func (p *Envsubst) render(text string, tokens []token, state *substitution) string {
+	pieces := make([]piece, 0, len(tokens))
+	for i := range tokens {
+		t := &tokens[i]
+		pc := piece{kind: pieceLiteral, start: t.offset, end: t.offset + len(t.raw), text: t.raw}
+
+		switch t.kind {
+		case tokenMalformed:
+			if err := p.checkMalformed(t, state); err != nil {
+				state.errs = append(state.errs, err)
+			}
+
+		case tokenEscaped:
+			if p.isUnescaped(t) {
+				pc.start++
+				pc.text = t.raw[1:]
+			}
+
+		case tokenPlaceholder:
+			value, resolved, err := p.expandPlaceholder(t, state)
+			if err != nil {
+				p.fail(t, err, state)
+			} else if resolved {
+				pc.text = value
+				pc.kind = pieceValue
+				if isFormatted(t.filters) {
+					pc.kind = pieceVerbatim
+				}
+				if hasFilter(t.filters, "autoindent") {
+					pc.kind = pieceIndented
+				}
+			}
+		}
+		pieces = append(pieces, pc)
+	}
+
+	spans := analyzeContexts(text, tokens, p.isJSON())
+	return escapeValues(text, pieces, spans)
+}
+
+// fail records an error of a placeholder, so all the failures of a document are reported at once.
+// With StrictOff a placeholder of a required variable (${VAR:?message}) remains unchanged instead.
+func (p *Envsubst) fail(t *token, err error, state *substitution) {
+	var required *requiredError
+	if p.strict == StrictOff && errors.As(err, &required) {
+		state.unresolved = append(state.unresolved, *t)
+		return
+	}
+	state.errs = append(state.errs, err)
+}
+
+// expand concatenates literal text and values of placeholders
+func (p *Envsubst) expand(tokens []token, state *substitution) (string, error) {
+	var sb strings.Builder
+	for i := range tokens {
+		t := &tokens[i]
+
+		switch t.kind {
+		case tokenText:
+			sb.WriteString(t.raw)
+
+		case tokenMalformed:
+			if err := p.checkMalformed(t, state); err != nil {
+				return "", err
+			}
+			sb.WriteString(t.raw)
+
+		case tokenEscaped:
+			if p.isUnescaped(t) {
+				sb.WriteString(t.raw[1:])
+			} else {
+				sb.WriteString(t.raw)
+			}
+
+		case tokenPlaceholder:
+			value, _, err := p.expandPlaceholder(t, state)
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(value)
+		}
+	}
+	return sb.String(), nil
+}
+
+// checkMalformed returns an error for a malformed or unterminated placeholder of a variable from filter lists.
+// Other malformed placeholders remain unchanged, they may be a parts of scripts, like ${array[0]},
+// with StrictOff all of them remain unchanged.
+func (p *Envsubst) checkMalformed(t *token, state *substitution) error {
+	// an unterminated reference has no path yet, so it is reported, when its scheme is enabled: ${vault:
+	if p.strict != StrictOff && (p.isAllowed(t) || (t.unterminated && t.scheme != "" && p.resolvers[t.scheme] != nil)) {
+		return malformedError(t)
+	}
+	state.unresolved = append(state.unresolved, *t)
+	return nil
+}
+
+// malformedError describes a malformed placeholder with its location
+func malformedError(t *token) error {
+	if t.unterminated {
+		return fmt.Errorf("%s: %w %s, %s", t.pos, errUnterminated, t.raw, t.detail)
+	}
+	return fmt.Errorf("%s: %s in placeholder %s", t.pos, t.detail, t.raw)
+}
+
+// isUnescaped checks whether an escaped placeholder loses its extra dollar sign: $${VAR} becomes ${VAR}.
+// Only placeholders of allowed variables and references are unescaped, others remain unchanged,
+// since $$ may be a part of a script or a Makefile, like echo $$f
+func (p *Envsubst) isUnescaped(t *token) bool {
+	return p.isAllowed(t)
+}
+
+// isAllowed checks whether a placeholder is substituted: a variable from filter lists, or a reference
+// of an enabled scheme, whose name is in filter lists as well: vault:secret/data/app#password
+func (p *Envsubst) isAllowed(t *token) bool {
+	if t.scheme != "" && p.resolvers[t.scheme] == nil {
+		return false
+	}
+	return p.isInFilter(t.name)
+}
+
+// expandPlaceholder returns the value of a placeholder passed through its filters,
+// or its original text when it cannot be resolved, the outcome is recorded in explain mode
+func (p *Envsubst) expandPlaceholder(t *token, state *substitution) (string, bool, error) {
+	named := t
+	if t.scheme == "" && (t.indirect || t.nameParts != nil) {
+		resolvedName, ok, err := p.resolveName(t, state)
+		if err != nil {
+			return "", false, err
+		}
+		if !ok {
+			p.explainPlaceholder(t, t, "", false, state)
+			return t.raw, false, nil
+		}
+		named = &resolvedName
+	}
+
+	value, resolved, err := p.expandNamed(named, state)
+	if err != nil {
+		return "", false, err
+	}
+	p.explainPlaceholder(t, named, value, resolved, state)
+	return value, resolved, nil
+}
+
+// expandNamed returns the value of a placeholder with a plain name, or of a reference
+func (p *Envsubst) expandNamed(t *token, state *substitution) (string, bool, error) {
+	if t.scheme != "" {
+		value, resolved, err := p.expandReference(t)
+		if err == nil && !resolved {
+			state.unresolved = append(state.unresolved, *t)
+		}
+		return value, resolved, err
+	}
+	value, resolved, err := p.resolve(t, state)
+	if err != nil {
+		return "", false, err
+	}
+	if !resolved {
+		state.unresolved = append(state.unresolved, *t)
+		return t.raw, false, nil
+	}
+	return p.filter(value, t)
+}
+
+// expandReference returns the value of a reference passed through its filters, or its original text
+// when there is no resolver for its scheme, or its name is not allowed by filter lists
+func (p *Envsubst) expandReference(t *token) (string, bool, error) {
+	resolver, ok := p.resolvers[t.scheme]
+	if !ok || !p.isInFilter(t.name) {
+		return t.raw, false, nil
+	}
+	value, err := resolver.Resolve(t.ref, p.filename)
+	if err != nil {
+		return "", false, fmt.Errorf("%s: %s: %w", t.pos, t.raw, err)
+	}
+	return p.filter(value, t)
+}
+
+// filter passes a resolved value of a placeholder through its filters
+func (p *Envsubst) filter(value string, t *token) (string, bool, error) {
+	if len(t.filters) > 0 {
+		var err error
+		value, err = applyFilters(value, t.filters)
+		if err != nil {
+			return "", false, fmt.Errorf("%s: %s: %w", t.pos, t.name, err)
+		}
+	}
+	return value, true, nil
+}
+
+// isJSON checks whether the processed file is a JSON document
+func (p *Envsubst) isJSON() bool {
+	return strings.EqualFold(filepath.Ext(p.filename), ".json")
+}
+
+// resolveName returns a copy of a placeholder with a nested or indirect name replaced by the name
+// of the variable it refers to. It reports false when an inner placeholder or a reference cannot be
+// resolved, so the placeholder remains unchanged.
+func (p *Envsubst) resolveName(t *token, state *substitution) (token, bool, error) {
+	state.depth++
+	defer func() { state.depth-- }()
+	if state.depth > maxExpansionDepth {
+		return token{}, false, fmt.Errorf("%s: expansion of %s is nested too deeply", t.pos, t.raw)
+	}
+
+	// nested name: ${DB_HOST_${DEPLOY_ENV}}, inner placeholders are resolved as usual
+	name := t.name
+	if t.nameParts != nil {
+		var sb strings.Builder
+		for i := range t.nameParts {
+			part := &t.nameParts[i]
+			if part.kind != tokenPlaceholder {
+				sb.WriteString(part.raw)
+				continue
+			}
+			value, resolved, err := p.expandPlaceholder(part, state)
+			if err != nil || !resolved {
+				return token{}, false, err
+			}
+			sb.WriteString(value)
+		}
+		name = sb.String()
+		if !isPath(name) {
+			return token{}, false, fmt.Errorf("%s: invalid variable name %q in placeholder %s", t.pos, name, t.raw)
+		}
+	}
+
+	// indirect reference: ${!DB_HOST_VAR}, the value of the variable is a name,
+	// that may contain placeholders as well: DB_HOST_VAR=DB_HOST_${DEPLOY_ENV}
+	if t.indirect {
+		reference, ok := state.envMap[name]
+		if !ok {
+			unresolved := *t
+			unresolved.name = name
+			state.unresolved = append(state.unresolved, unresolved)
+			return token{}, false, nil
+		}
+		if varInSlice(name, state.references) {
+			cycle := append(append([]string{}, state.references...), name)
+			return token{}, false, fmt.Errorf("%s: cyclic indirect reference: %s", t.pos, strings.Join(cycle, " -> "))
+		}
+		state.references = append(state.references, name)
+		defer func() { state.references = state.references[:len(state.references)-1] }()
+
+		target, err := parseReference(reference, t, state.delims)
+		if err != nil {
+			return token{}, false, err
+		}
+		if target.nameParts == nil {
+			name = target.name
+		} else {
+			named, ok, err := p.resolveName(&target, state)
+			if err != nil || !ok {
+				return token{}, false, err
+			}
+			name = named.name
+		}
+	}
+
+	named := *t
+	named.name = name
+	named.nameParts = nil
+	named.indirect = false
+	return named, true, nil
+}
+
+// parseReference parses the value of an indirect reference as a name of a variable,
+// placeholders of the name are reported at the position of the placeholder t
+func parseReference(reference string, t *token, delims delimiters) (token, error) {
+	s := newScanner(reference, t.pos.filename).withDelimiters(delims)
+	parts, end, err := s.scanNameParts(0)
+	if err == nil && (end != len(reference) || len(parts) == 0 || malformedDetail(parts) != "") {
+		err = fmt.Errorf("invalid variable name %q", reference)
+	}
+	if err != nil {
+		return token{}, fmt.Errorf("%s: %s: %w", t.pos, t.raw, err)
+	}
+
+	target := token{kind: tokenPlaceholder, raw: t.raw, offset: t.offset, pos: t.pos, name: reference}
+	if len(parts) > 1 || parts[0].kind != tokenText {
+		target.nameParts = relocate(parts, t.pos)
+	}
+	return target, nil
+}
+
+// relocate sets the position of tokens, that were scanned from a value, to the position of a placeholder
+func relocate(tokens []token, pos position) []token {
+	for i := range tokens {
+		tokens[i].pos = pos
+		tokens[i].word = relocate(tokens[i].word, pos)
+		tokens[i].nameParts = relocate(tokens[i].nameParts, pos)
+	}
+	return tokens
+}
+
+// resolve returns the value of a placeholder, and whether it was resolved
+func (p *Envsubst) resolve(t *token, state *substitution) (string, bool, error) {
+	// plain placeholder: ${VAR} or $VAR, or a string manipulation: ${VAR^^}, etc...
+	if t.operator == "" || isManipulation(t.operator) {
+		// get value, according to filters
+		value, ok := state.envMap[t.name]
+		if !ok {
+			return "", false, nil
+		}
+		if t.operator == "" {
+			return value, true, nil
+		}
+		result, err := p.manipulate(value, t, state)
+		if err != nil {
+			return "", false, fmt.Errorf("%s: %s: %w", t.pos, t.name, err)
+		}
+		return result, true, nil
+	}
+
+	// placeholder with operator: ${VAR:-default}, etc...
+	// variables that are not in filter lists remain unchanged, like plain ones
+	if !p.isInFilter(t.name) {
+		return "", false, nil
+	}
+
+	value, err := p.applyOperator(t, state)
+	if err != nil {
+		return "", false, fmt.Errorf("%s: %w", t.pos, err)
+	}
+	return value, true, nil
+}
+
+// manipulate applies a string manipulation operator to the value of a variable
+func (p *Envsubst) manipulate(value string, t *token, state *substitution) (string, error) {
+	switch t.operator {
+	case "^^", "^", ",,", ",":
+		if len(t.word) > 0 {
+			return "", fmt.Errorf("unexpected operand after %q", t.operator)
+		}
+		return changeCase(value, t.operator), nil
+
+	case ":":
+		operand, err := p.expand(t.word, state)
+		if err != nil {
+			return "", err
+		}
+		return substring(value, operand)
+
+	case "#", "##", "%", "%%":
+		pattern, err := p.expand(t.word, state)
+		if err != nil {
+			return "", err
+		}
+		return removePattern(value, t.operator, pattern)
+	}
+
+	// replacement: ${VAR/pattern/string}, the string is optional
+	patternTokens, replacementTokens := splitTokens(t.word, '/')
+	pattern, err := p.expand(patternTokens, state)
+	if err != nil {
+		return "", err
+	}
+	replacement, err := p.expand(replacementTokens, state)
+	if err != nil {
+		return "", err
+	}
+	return replacePattern(value, t.operator, pattern, replacement)
+}
+
+// applyOperator evaluates a shell-style operator with POSIX semantics.
+// The colon form treats a variable with an empty value the same as an unset one.
+func (p *Envsubst) applyOperator(t *token, state *substitution) (string, error) {
+	value, isSet := state.envMap[t.name]
+	if strings.HasPrefix(t.operator, ":") && value == "" {
+		isSet = false
+	}
+
+	switch strings.TrimPrefix(t.operator, ":") {
+	case "-":
+		if isSet {
+			return value, nil
+		}
+		return p.expand(t.word, state)
+	case "=":
+		if isSet {
+			return value, nil
+		}
+		expanded, err := p.expand(t.word, state)
+		if err != nil {
+			return "", err
+		}
+		state.envMap[t.name] = expanded
+		return expanded, nil
+	case "+":
+		if isSet {
+			return p.expand(t.word, state)
+		}
+		return "", nil
+	case "?":
+		if isSet {
+			return value, nil
+		}
+		message, err := p.expand(t.word, state)
+		if err != nil {
+			return "", err
+		}
+		if message == "" {
+			message = "parameter null or not set"
+		}
+		return "", &requiredError{name: t.name, message: message}
+	}
+
+	return "", fmt.Errorf("unsupported operator %q for variable: %s", t.operator, t.name)
+}
+
+// requiredError is a failure of a required variable, that is unset: ${VAR:?message}
+type requiredError struct {
+	name    string
+	message string
+}
+
+func (e *requiredError) Error() string {
+	return e.name + ": " + e.message
+}
+
 // collectAllowedEnvVars collects variables and prefixes allowed for substitution
 func (p *Envsubst) collectAllowedEnvVars() map[string]string {
 	envMap := make(map[string]string)
 
 	// Collect variables in the allowedVars list
 	for _, env := range p.allowedVars {
-		if value, exists := os.LookupEnv(env); exists {
+		if p.denyRule(env) != "" {
+			continue
+		}
+		if value, exists := p.source.Lookup(env); exists {
 			envMap[env] = value
 		}
 	}
 
-	// Collect variables matching allowed prefixes
-	globalEnv := preprocessEnv()
-	for _, prefix := range p.allowedPrefixes {
-		for key, value := range globalEnv {
-			if strings.HasPrefix(key, prefix) {
+	// Collect variables matching allowed prefixes, and paths nested in allowed variables: db.primary.host for db
+	for _, key := range p.source.Names() {
+		if _, collected := envMap[key]; !collected && p.isInFilter(key) {
+			if value, exists := p.source.Lookup(key); exists {
 				envMap[key] = value
 			}
 		}
 	}
 
+	// Collect built-in variables, that are not overridden by the source
+	for name, value := range p.builtins {
+		if _, collected := envMap[name]; !collected && p.denyRule(name) == "" {
+			envMap[name] = value
+		}
+	}
+
 	return envMap
 }
 
@@ -104,14 +700,44 @@ func preprocessEnv() map[string]string {
 	return envMap
 }
 
-// checkUnresolvedStrictMode checks unresolved variables in strict mode
-func (p *Envsubst) checkUnresolvedStrictMode(substituted string) error {
-	unresolved := envVarRegex.FindAllString(substituted, -1)
-	if p.strict {
-		filtered := p.filterUnresolvedByAllowedLists(unresolved)
+// checkUnresolvedStrictMode checks unresolved variables according to the strictness level
+func (p *Envsubst) checkUnresolvedStrictMode(unresolved []token) error {
+	if p.strict == StrictAll {
+		return p.checkUnresolvedAll(unresolved)
+	}
+	if p.strict == StrictFiltered {
+		filtered := p.filterUnresolvedByAllowedLists(tokenNames(unresolved))
 		if len(filtered) > 0 {
-			return fmt.Errorf("undefined variables: [%s]", strings.Join(filtered, ", "))
+			// report each occurrence, so the exact place may be found in a large manifest
+			locations := []string{}
+			for _, t := range unresolved {
+				if varInSlice(t.name, filtered) {
+					locations = append(locations, fmt.Sprintf("  %s: %s", t.pos, t.raw))
+				}
+			}
+			return fmt.Errorf("undefined variables: [%s]\n%s", strings.Join(filtered, ", "), strings.Join(locations, "\n"))
+		}
+	}
+	return nil
+}
+
+// checkUnresolvedAll returns an error for every placeholder, that remains in the document,
+// placeholders of denied variables are reported by checkDenied, when its policy is DeniedPolicyError
+func (p *Envsubst) checkUnresolvedAll(unresolved []token) error {
+	names := []string{}
+	locations := []string{}
+	for _, t := range unresolved {
+		if p.deniedPolicy == DeniedPolicyError && p.denyRule(t.name) != "" {
+			continue
+		}
+		if !varInSlice(t.name, names) {
+			names = append(names, t.name)
 		}
+		locations = append(locations, fmt.Sprintf("  %s: %s", t.pos, t.raw))
+	}
+	if len(names) > 0 {
+		sort.Strings(names)
+		return fmt.Errorf("unresolved placeholders: [%s]\n%s", strings.Join(names, ", "), strings.Join(locations, "\n"))
 	}
 	return nil
 }
@@ -120,6 +746,17 @@ func (p *Envsubst) checkUnresolvedStrictMode(substituted string) error {
 func (p *Envsubst) logUnresolvedVariables(unresolved []string) {
 	if p.verbose {
 		for _, variable := range p.sortUnresolved(unresolved) {
+			if p.isInFilter(variable) {
+				// allowed variables are reported as errors in strict mode
+				if p.strict == StrictOff {
+					log.Printf("DEBUG: an undefined variable from the filter list remains unchanged: %s", variable)
+				}
+				continue
+			}
+			if rule := p.denyRule(variable); rule != "" {
+				log.Printf("DEBUG: a denied variable remains unchanged: %s (denied by %s)", variable, rule)
+				continue
+			}
 			log.Printf("DEBUG: an unresolved variable that is not in the filter list remains unchanged: %s", variable)
 		}
 	}
@@ -138,19 +775,75 @@ func (p *Envsubst) filterUnresolvedByAllowedLists(input []string) []string {
 	return result
 }
 
-// isInFilter checks if a variable is in the allowed lists
+// isInFilter checks if a variable is built-in, or is in the allowed lists,
+// a path is allowed with its root: db.primary.host and images[0].tag for db and images,
+// references are checked by their names: vault:secret/data/app#password
 func (p *Envsubst) isInFilter(e string) bool {
+	return p.allowRule(e) != ""
+}
+
+// allowRule describes the rule, that allows a variable, or returns an empty string
+func (p *Envsubst) allowRule(e string) string {
+	if p.denyRule(e) != "" {
+		return ""
+	}
+	// hashes of files do not expose their content: ENVSUBST_HASH:configmap.yaml
+	if _, builtin := p.builtins[e]; builtin || strings.HasPrefix(e, "ENVSUBST_HASH:") {
+		return "built-in"
+	}
 	for _, allowed := range p.allowedVars {
-		if e == allowed {
-			return true
+		if e == allowed || strings.HasPrefix(e, allowed+".") || strings.HasPrefix(e, allowed+"[") {
+			return "var " + allowed
 		}
 	}
 	for _, prefix := range p.allowedPrefixes {
 		if strings.HasPrefix(e, prefix) {
-			return true
+			return "prefix " + prefix
 		}
 	}
-	return false
+	if pattern, ok := matchPatterns(p.allowedPatterns, e); ok {
+		return "pattern " + pattern
+	}
+	return ""
+}
+
+// denyRule describes the rule, that denies a variable, or returns an empty string,
+// a path is denied with its root: db.password for db
+func (p *Envsubst) denyRule(e string) string {
+	for _, denied := range p.deniedVars {
+		if e == denied || strings.HasPrefix(e, denied+".") || strings.HasPrefix(e, denied+"[") {
+			return "var " + denied
+		}
+	}
+	for _, prefix := range p.deniedPrefixes {
+		if strings.HasPrefix(e, prefix) {
+			return "prefix " + prefix
+		}
+	}
+	return ""
+}
+
+// checkDenied returns an error for placeholders of denied variables, when the policy is DeniedPolicyError
+func (p *Envsubst) checkDenied(unresolved []token) error {
+	if p.deniedPolicy != DeniedPolicyError {
+		return nil
+	}
+	denied := []string{}
+	locations := []string{}
+	for _, t := range unresolved {
+		if p.denyRule(t.name) == "" {
+			continue
+		}
+		if !varInSlice(t.name, denied) {
+			denied = append(denied, t.name)
+		}
+		locations = append(locations, fmt.Sprintf("  %s: %s", t.pos, t.raw))
+	}
+	if len(denied) > 0 {
+		sort.Strings(denied)
+		return fmt.Errorf("denied variables: [%s]\n%s", strings.Join(denied, ", "), strings.Join(locations, "\n"))
+	}
+	return nil
 }
 
 // sortUnresolved removes duplicates and sorts unresolved variables
@@ -175,3 +868,20 @@ func varInSlice(target string, slice []string) bool {
 	}
 	return false
 }
+
+// strictLevel returns the strictness level of the strict argument of NewEnvsubst
+func strictLevel(strict bool) string {
+	if strict {
+		return StrictFiltered
+	}
+	return StrictOff
+}
+
+// tokenNames returns variable names of tokens
+func tokenNames(tokens []token) []string {
+	result := make([]string, 0, len(tokens))
+	for i := range tokens {
+		result = append(result, tokens[i].name)
+	}
+	return result
+}
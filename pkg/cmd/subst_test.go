@@ -3,6 +3,7 @@ package cmd
 import (
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -223,38 +224,80 @@ spec:
 	}
 }
 
-func TestRegex(t *testing.T) {
-	testCases := []struct {
-		input    string
-		expected []string
+func TestSubstituteEnvs_MalformedPlaceholders_Errors(t *testing.T) {
+	os.Setenv("APP_NAME", "my-app")
+	defer os.Unsetenv("APP_NAME")
+
+	tests := []struct {
+		name          string
+		input         string
+		expected      string
+		expectedError string
 	}{
-		{"$VAR", []string{"VAR"}},
-		{"${VAR}", []string{"VAR"}},
-		{"$1VAR", nil},
-		{"$(VAR)", nil},
-		{"${VAR1_2}", []string{"VAR1_2"}},
-		{"No variable here", nil},
+		{
+			name:     "Stray closing brace is not consumed",
+			input:    "labels: {app: $APP_NAME}",
+			expected: "labels: {app: my-app}",
+		},
+		{
+			name:          "Unterminated placeholder",
+			input:         "name: ${APP_NAME",
+			expectedError: "deployment.yaml:1:7: unterminated placeholder ${APP_NAME, missing '}'",
+		},
+		{
+			name:     "Unterminated placeholder not in filter",
+			input:    "a: ${OTHER $APP_NAME",
+			expected: "a: ${OTHER my-app",
+		},
+		{
+			name:     "Template literal spanning lines",
+			input:    "app.js: |\n  const list = `${items.map(i =>\n    i.name)}`",
+			expected: "app.js: |\n  const list = `${items.map(i =>\n    i.name)}`",
+		},
+		{
+			name:          "Malformed placeholder in filter",
+			input:         "\nname: ${APP_NAME[first]}",
+			expectedError: "deployment.yaml:2:7: unexpected character '[' in placeholder ${APP_NAME[first]}",
+		},
+		{
+			name:     "Malformed placeholder not in filter",
+			input:    "echo ${array[0]} ${user.name}",
+			expected: "echo ${array[0]} ${user.name}",
+		},
+		{
+			name:          "Operator error",
+			input:         "\n\nimage: ${APP_IMAGE:?image is required}",
+			expectedError: "deployment.yaml:3:8: APP_IMAGE: image is required",
+		},
+		{
+			name:          "Strict mode",
+			input:         "name: $APP_NAME\nimage: ${APP_IMAGE}\ntag: $APP_TAG\nsidecar: ${APP_IMAGE}",
+			expectedError: "undefined variables: [APP_IMAGE, APP_TAG]\n  deployment.yaml:2:8: ${APP_IMAGE}\n  deployment.yaml:3:6: $APP_TAG\n  deployment.yaml:4:10: ${APP_IMAGE}",
+		},
 	}
 
-	for _, tc := range testCases {
-		matches := envVarRegex.FindAllStringSubmatch(tc.input, -1)
-		var result []string
-		for _, match := range matches {
-			if len(match) > 1 {
-				result = append(result, match[1])
-			}
-		}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			envsubst := NewEnvsubst([]string{}, []string{"APP_"}, true)
+			envsubst.SetFilename("deployment.yaml")
 
-		if len(result) != len(tc.expected) {
-			t.Errorf("For input '%s', expected %v, got %v", tc.input, tc.expected, result)
-			continue
-		}
-		for i, v := range result {
-			if v != tc.expected[i] {
-				t.Errorf("For input '%s', expected %v, got %v", tc.input, tc.expected, result)
-				break
+			result, err := envsubst.SubstituteEnvs(test.input)
+			if test.expectedError != "" {
+				if err == nil {
+					t.Fatal("Expected an error, but got none")
+				}
+				if err.Error() != test.expectedError {
+					t.Errorf("Expected error '%s', got '%s'", test.expectedError, err.Error())
+				}
+				return
 			}
-		}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result != test.expected {
+				t.Errorf("Expected '%s', got '%s'", test.expected, result)
+			}
+		})
 	}
 }
 
@@ -993,14 +1036,17 @@ func TestCollectAllowedEnvVars(t *testing.T) {
 // Test for checkUnresolvedStrictMode
 func TestCheckUnresolvedStrictMode(t *testing.T) {
 	envsubst := NewEnvsubst([]string{"VAR1"}, []string{"PREFIX_"}, true)
-	input := "Hello ${VAR1}!"
+	input := []token{
+		{kind: tokenPlaceholder, raw: "${VAR1}", name: "VAR1", pos: position{line: 1, column: 7}},
+		{kind: tokenPlaceholder, raw: "${VAR2}", name: "VAR2", pos: position{line: 2, column: 7}},
+	}
 
 	err := envsubst.checkUnresolvedStrictMode(input)
 	if err == nil {
 		t.Fatal("Expected an error for unresolved variables in strict mode, but got none")
 	}
 
-	expected := "undefined variables: [VAR1]"
+	expected := "undefined variables: [VAR1]\n  1:7: ${VAR1}"
 	if err.Error() != expected {
 		t.Errorf("Expected error '%s', got '%s'", expected, err.Error())
 	}
@@ -1139,3 +1185,890 @@ $E_$X$C$E$P$T$E$U$R $S$I$N$T $O$C$C$A$E$C$A$T $C$U$P$I$D$A$T$A$T $N$O$N $P$R$O$I
 		t.Fatal("Texts are diff")
 	}
 }
+
+func TestSubstituteEnvs_ShellOperators(t *testing.T) {
+	os.Setenv("APP_SET", "value")
+	os.Setenv("APP_EMPTY", "")
+	os.Setenv("APP_OTHER", "other")
+	defer os.Unsetenv("APP_SET")
+	defer os.Unsetenv("APP_EMPTY")
+	defer os.Unsetenv("APP_OTHER")
+
+	tests := []struct {
+		name        string
+		input       string
+		expected    string
+		expectError bool
+	}{
+		// ${VAR:-default} and ${VAR-default}
+		{name: "Colon default, set", input: "${APP_SET:-def}", expected: "value"},
+		{name: "Colon default, empty", input: "${APP_EMPTY:-def}", expected: "def"},
+		{name: "Colon default, unset", input: "${APP_UNSET:-def}", expected: "def"},
+		{name: "Default, set", input: "${APP_SET-def}", expected: "value"},
+		{name: "Default, empty", input: "${APP_EMPTY-def}", expected: ""},
+		{name: "Default, unset", input: "${APP_UNSET-def}", expected: "def"},
+		{name: "Empty default", input: "[${APP_UNSET:-}]", expected: "[]"},
+		{name: "Default with reference", input: "${APP_UNSET:-$APP_OTHER}", expected: "other"},
+
+		// ${VAR:+alt} and ${VAR+alt}
+		{name: "Colon alternate, set", input: "${APP_SET:+alt}", expected: "alt"},
+		{name: "Colon alternate, empty", input: "${APP_EMPTY:+alt}", expected: ""},
+		{name: "Colon alternate, unset", input: "${APP_UNSET:+alt}", expected: ""},
+		{name: "Alternate, empty", input: "${APP_EMPTY+alt}", expected: "alt"},
+		{name: "Alternate, unset", input: "${APP_UNSET+alt}", expected: ""},
+
+		// ${VAR:=default} and ${VAR=default}
+		{name: "Assign default, unset", input: "${APP_UNSET=def} $APP_UNSET", expected: "def def"},
+		{name: "Colon assign default, empty", input: "${APP_EMPTY:=def} ${APP_EMPTY}", expected: "def def"},
+		{name: "Assign default, set", input: "${APP_SET=def} $APP_SET", expected: "value value"},
+
+		// ${VAR:?message} and ${VAR?message}
+		{name: "Colon error, set", input: "${APP_SET:?required}", expected: "value"},
+		{name: "Colon error, empty", input: "${APP_EMPTY:?required}", expectError: true},
+		{name: "Colon error, unset", input: "${APP_UNSET:?required}", expectError: true},
+		{name: "Error, empty", input: "[${APP_EMPTY?required}]", expected: "[]"},
+		{name: "Error, unset, no message", input: "${APP_UNSET?}", expectError: true},
+
+		// variables that are not in filter lists remain unchanged
+		{name: "Not in filter", input: "${OTHER:-def} ${OTHER:?required}", expected: "${OTHER:-def} ${OTHER:?required}"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			envsubst := NewEnvsubst([]string{}, []string{"APP_"}, true)
+			result, err := envsubst.SubstituteEnvs(test.input)
+			if (err != nil) != test.expectError {
+				t.Fatalf("Unexpected error status: got %v, want error=%v", err, test.expectError)
+			}
+			if result != test.expected {
+				t.Errorf("Expected '%s', got '%s'", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestSubstituteEnvs_ShellOperators_Pipe(t *testing.T) {
+	envsubst := NewEnvsubst([]string{}, []string{"APP_"}, true, MapSource{"APP_LIST": "a,b", "APP_MODE": "x|y"})
+
+	// '|' is a part of operands, unless whitespace precedes it
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{input: "${APP_UNSET:-a|b}", expected: "a|b"},
+		{input: "${APP_LIST/,/|}", expected: "a|b"},
+		{input: "${APP_MODE/x|y/z}", expected: "z"},
+		{input: "${APP_MODE#x|}", expected: "y"},
+		{input: "${APP_UNSET:-a|b | upper}", expected: "A|B"},
+		{input: "${APP_UNSET:-a | upper}", expected: "A"},
+	}
+
+	for _, test := range tests {
+		result, err := envsubst.SubstituteEnvs(test.input)
+		if err != nil {
+			t.Fatalf("Unexpected error for %s: %v", test.input, err)
+		}
+		if result != test.expected {
+			t.Errorf("For %s, expected '%s', got '%s'", test.input, test.expected, result)
+		}
+	}
+}
+
+func TestSubstituteEnvs_ShellOperators_ErrorMessage(t *testing.T) {
+	envsubst := NewEnvsubst([]string{"APP_UNSET"}, []string{}, true)
+
+	_, err := envsubst.SubstituteEnvs("image: ${APP_UNSET:?image is required}")
+	if err == nil {
+		t.Fatal("Expected an error for unset variable, but got none")
+	}
+
+	expectedError := "1:8: APP_UNSET: image is required"
+	if err.Error() != expectedError {
+		t.Errorf("Expected error '%s', got '%s'", expectedError, err.Error())
+	}
+}
+
+func TestSubstituteEnvs_EscapedPlaceholders(t *testing.T) {
+	os.Setenv("APP_HOST", "example.com")
+	defer os.Unsetenv("APP_HOST")
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "Escaped plain", input: "$$APP_HOST", expected: "$APP_HOST"},
+		{name: "Escaped braced", input: "$${APP_HOST}", expected: "${APP_HOST}"},
+		{name: "Escaped with operator", input: "$${APP_HOST:-$APP_HOST}", expected: "${APP_HOST:-$APP_HOST}"},
+		{name: "Escaped and substituted", input: "$$APP_HOST=$APP_HOST", expected: "$APP_HOST=example.com"},
+		{name: "Escaped unresolved", input: "$${APP_UNSET}", expected: "${APP_UNSET}"},
+		{name: "Dollar signs without placeholder", input: "kill -9 $$; echo $$ $", expected: "kill -9 $$; echo $$ $"},
+		{name: "Script snippet", input: "echo \"$${PATH}:$$APP_HOME\" > /tmp/$APP_HOST", expected: "echo \"$${PATH}:$APP_HOME\" > /tmp/example.com"},
+		{name: "Escaped not allowed", input: "for f in *; do echo $$f $${HOME}; done", expected: "for f in *; do echo $$f $${HOME}; done"},
+		{name: "Escaped in operand", input: "${APP_UNSET:-$${APP_HOST} $$HOME}", expected: "${APP_HOST} $$HOME"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			envsubst := NewEnvsubst([]string{}, []string{"APP_"}, true)
+			result, err := envsubst.SubstituteEnvs(test.input)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result != test.expected {
+				t.Errorf("Expected '%s', got '%s'", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestSubstituteEnvs_AllowedPatterns(t *testing.T) {
+	source := MapSource{
+		"NGINX_IMAGE": "nginx:1.27",
+		"APP_NAME":    "web",
+		"APP_DEBUG":   "true",
+		"HOME":        "/root",
+	}
+
+	tests := []struct {
+		name          string
+		strict        bool
+		input         string
+		expected      string
+		expectedError string
+		expectedLog   string
+	}{
+		{
+			name:     "Globs and negated patterns",
+			input:    "image: $NGINX_IMAGE\nname: ${APP_NAME}\ndebug: ${APP_DEBUG}\nhome: $HOME",
+			expected: "image: nginx:1.27\nname: web\ndebug: ${APP_DEBUG}\nhome: $HOME",
+		},
+		{
+			name:          "Strict mode reports variables of patterns",
+			strict:        true,
+			input:         "image: ${REDIS_IMAGE}\ndebug: ${APP_DEBUG}",
+			expectedError: "undefined variables: [REDIS_IMAGE]\n  deployment.yaml:1:8: ${REDIS_IMAGE}",
+		},
+		{
+			name:        "Verbose log tells variables of patterns apart",
+			input:       "image: ${REDIS_IMAGE}\ndebug: ${APP_DEBUG}",
+			expected:    "image: ${REDIS_IMAGE}\ndebug: ${APP_DEBUG}",
+			expectedLog: "DEBUG: an unresolved variable that is not in the filter list remains unchanged: APP_DEBUG\nDEBUG: an undefined variable from the filter list remains unchanged: REDIS_IMAGE\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			envsubst := NewEnvsubst([]string{}, []string{}, test.strict, source)
+			envsubst.SetFilename("deployment.yaml")
+			envsubst.SetVerbose(true)
+			if err := envsubst.SetAllowedPatterns([]string{"*_IMAGE", "APP_*", "!APP_DEBUG"}); err != nil {
+				t.Fatal(err)
+			}
+
+			logBuffer := strings.Builder{}
+			log.SetOutput(&logBuffer)
+			log.SetFlags(0)
+			defer log.SetOutput(os.Stderr)
+			defer log.SetFlags(log.LstdFlags)
+
+			result, err := envsubst.SubstituteEnvs(test.input)
+			if test.expectedError != "" {
+				if err == nil || err.Error() != test.expectedError {
+					t.Errorf("Expected error '%s', got '%v'", test.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result != test.expected {
+				t.Errorf("Expected '%s', got '%s'", test.expected, result)
+			}
+			if test.expectedLog != "" && logBuffer.String() != test.expectedLog {
+				t.Errorf("Expected log '%s', got '%s'", test.expectedLog, logBuffer.String())
+			}
+		})
+	}
+}
+
+func TestSubstituteEnvs_Denied(t *testing.T) {
+	source := MapSource{
+		"CI_PROJECT_NAME":      "web",
+		"CI_JOB_TOKEN":         "token",
+		"CI_REGISTRY_PASSWORD": "password",
+		"APP_TOKEN_REF":        "CI_JOB_TOKEN",
+		"db.password":          "s3cr3t",
+		"db.host":              "db.local",
+	}
+
+	tests := []struct {
+		name          string
+		policy        string
+		input         string
+		expected      string
+		expectedError string
+	}{
+		{
+			name:     "Denied variables remain unchanged",
+			policy:   DeniedPolicyIgnore,
+			input:    "name: ${CI_PROJECT_NAME}\ntoken: $CI_JOB_TOKEN\npassword: ${CI_REGISTRY_PASSWORD:-none}\nref: ${!APP_TOKEN_REF}",
+			expected: "name: web\ntoken: $CI_JOB_TOKEN\npassword: ${CI_REGISTRY_PASSWORD:-none}\nref: ${!APP_TOKEN_REF}",
+		},
+		{
+			name:     "Denied paths and built-ins",
+			policy:   DeniedPolicyIgnore,
+			input:    "host: ${db.host}\npassword: ${db.password}\nuuid: ${ENVSUBST_UUID}",
+			expected: "host: db.local\npassword: ${db.password}\nuuid: ${ENVSUBST_UUID}",
+		},
+		{
+			name:          "Denied variables are errors",
+			policy:        DeniedPolicyError,
+			input:         "name: ${CI_PROJECT_NAME}\ntoken: $CI_JOB_TOKEN\npassword: ${CI_REGISTRY_PASSWORD:-none}\nagain: ${CI_JOB_TOKEN}",
+			expectedError: "denied variables: [CI_JOB_TOKEN, CI_REGISTRY_PASSWORD]\n  deployment.yaml:2:8: $CI_JOB_TOKEN\n  deployment.yaml:3:11: ${CI_REGISTRY_PASSWORD:-none}\n  deployment.yaml:4:8: ${CI_JOB_TOKEN}",
+		},
+		{
+			name:          "Denied variables are not strict mode errors",
+			policy:        DeniedPolicyIgnore,
+			input:         "token: ${CI_JOB_TOKEN} ${CI_UNDEFINED}",
+			expectedError: "undefined variables: [CI_UNDEFINED]\n  deployment.yaml:1:24: ${CI_UNDEFINED}",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			envsubst := NewEnvsubst([]string{"db", "CI_JOB_TOKEN"}, []string{"CI_", "APP_"}, true, source)
+			envsubst.SetFilename("deployment.yaml")
+			envsubst.AddBuiltins(map[string]string{"ENVSUBST_UUID": "1234"})
+			envsubst.SetDenied([]string{"CI_JOB_TOKEN", "db.password", "ENVSUBST_UUID"}, []string{"CI_REGISTRY_"})
+			if err := envsubst.SetDeniedPolicy(test.policy); err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := envsubst.SubstituteEnvs(test.input)
+			if test.expectedError != "" {
+				if err == nil || err.Error() != test.expectedError {
+					t.Errorf("Expected error '%s', got '%v'", test.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result != test.expected {
+				t.Errorf("Expected '%s', got '%s'", test.expected, result)
+			}
+		})
+	}
+
+	if err := NewEnvsubst(nil, nil, true).SetDeniedPolicy("warn"); err == nil {
+		t.Error("Expected an error for an invalid policy, but got none")
+	}
+}
+
+func TestSubstituteEnvs_StrictLevels(t *testing.T) {
+	source := MapSource{"APP_NAME": "web", "CI_JOB_TOKEN": "token"}
+	input := strings.Join([]string{
+		"name: ${APP_NAME}",
+		"image: ${APP_IMAGE}",
+		"home: $HOME ${HOME:-/root}",
+		"script: ${array[first]} $${APP_ESCAPED}",
+		"cert: ${file:tls.crt}",
+		"token: ${CI_JOB_TOKEN} ${APP_IMAGE}",
+	}, "\n")
+
+	tests := []struct {
+		name          string
+		level         string
+		policy        string
+		expectedError string
+	}{
+		{
+			name:  "Off never fails",
+			level: StrictOff,
+		},
+		{
+			name:          "Filtered fails on variables of the allowed lists",
+			level:         StrictFiltered,
+			expectedError: "undefined variables: [APP_IMAGE]\n  deployment.yaml:2:8: ${APP_IMAGE}\n  deployment.yaml:6:24: ${APP_IMAGE}",
+		},
+		{
+			name:  "All fails on any remaining placeholder",
+			level: StrictAll,
+			expectedError: "unresolved placeholders: [APP_IMAGE, CI_JOB_TOKEN, HOME, array, file:tls.crt]\n" +
+				"  deployment.yaml:2:8: ${APP_IMAGE}\n" +
+				"  deployment.yaml:3:7: $HOME\n" +
+				"  deployment.yaml:3:13: ${HOME:-/root}\n" +
+				"  deployment.yaml:4:9: ${array[first]}\n" +
+				"  deployment.yaml:5:7: ${file:tls.crt}\n" +
+				"  deployment.yaml:6:8: ${CI_JOB_TOKEN}\n" +
+				"  deployment.yaml:6:24: ${APP_IMAGE}",
+		},
+		{
+			name:   "Errors of the strictness level and of denied variables are aggregated",
+			level:  StrictAll,
+			policy: DeniedPolicyError,
+			expectedError: "unresolved placeholders: [APP_IMAGE, HOME, array, file:tls.crt]\n" +
+				"  deployment.yaml:2:8: ${APP_IMAGE}\n" +
+				"  deployment.yaml:3:7: $HOME\n" +
+				"  deployment.yaml:3:13: ${HOME:-/root}\n" +
+				"  deployment.yaml:4:9: ${array[first]}\n" +
+				"  deployment.yaml:5:7: ${file:tls.crt}\n" +
+				"  deployment.yaml:6:24: ${APP_IMAGE}\n" +
+				"denied variables: [CI_JOB_TOKEN]\n" +
+				"  deployment.yaml:6:8: ${CI_JOB_TOKEN}",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			envsubst := NewEnvsubst([]string{}, []string{"APP_", "CI_"}, true, source)
+			envsubst.SetFilename("deployment.yaml")
+			envsubst.SetDenied([]string{"CI_JOB_TOKEN"}, nil)
+			if err := envsubst.SetStrict(test.level); err != nil {
+				t.Fatal(err)
+			}
+			if test.policy != "" {
+				if err := envsubst.SetDeniedPolicy(test.policy); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			result, err := envsubst.SubstituteEnvs(input)
+			if test.expectedError != "" {
+				if err == nil || err.Error() != test.expectedError {
+					t.Errorf("Expected error '%s', got '%v'", test.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			expected := strings.Replace(strings.Replace(input, "${APP_NAME}", "web", 1), "$${APP_ESCAPED}", "${APP_ESCAPED}", 1)
+			if result != expected {
+				t.Errorf("Expected '%s', got '%s'", expected, result)
+			}
+		})
+	}
+
+	if err := NewEnvsubst(nil, nil, true).SetStrict("strict"); err == nil {
+		t.Error("Expected an error for an invalid strict level, but got none")
+	}
+}
+
+func TestSubstituteEnvs_StrictOff(t *testing.T) {
+	// nothing fails with StrictOff, required variables and malformed placeholders remain unchanged
+	envsubst := NewEnvsubst([]string{}, []string{"APP_"}, true, MapSource{})
+	envsubst.SetFilename("deployment.yaml")
+	if err := envsubst.SetStrict(StrictOff); err != nil {
+		t.Fatal(err)
+	}
+	input := "image: ${APP_IMAGE:?is required}\nname: ${APP_NAME[first]}\nport: ${APP_PORT}\nhost: ${APP_HOST"
+	result, err := envsubst.SubstituteEnvs(input)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result != input {
+		t.Errorf("Expected '%s', got '%s'", input, result)
+	}
+}
+
+func TestSubstituteEnvs_AggregatedErrors(t *testing.T) {
+	// failed placeholders are reported together with undefined variables
+	envsubst := NewEnvsubst([]string{}, []string{"APP_"}, true, MapSource{})
+	envsubst.SetFilename("deployment.yaml")
+	_, err := envsubst.SubstituteEnvs("image: ${APP_IMAGE:?is required}\nname: ${APP_NAME[first]}\nport: ${APP_PORT}\nhost: ${APP_HOST")
+	expectedError := "deployment.yaml:1:8: APP_IMAGE: is required\n" +
+		"deployment.yaml:2:7: unexpected character '[' in placeholder ${APP_NAME[first]}\n" +
+		"deployment.yaml:4:7: unterminated placeholder ${APP_HOST, missing '}'\n" +
+		"undefined variables: [APP_PORT]\n  deployment.yaml:3:7: ${APP_PORT}"
+	if err == nil || err.Error() != expectedError {
+		t.Errorf("Expected error '%s', got '%v'", expectedError, err)
+	}
+}
+
+func TestSubstituteEnvs_EscapedPlaceholders_NotReported(t *testing.T) {
+	os.Setenv("APP_VALUE", "$APP_UNSET")
+	defer os.Unsetenv("APP_VALUE")
+
+	envsubst := NewEnvsubst([]string{}, []string{"APP_"}, true)
+	envsubst.SetVerbose(true)
+
+	logBuffer := strings.Builder{}
+	log.SetOutput(&logBuffer)
+	defer log.SetOutput(os.Stderr)
+
+	// neither escaped placeholders, nor substituted values are unresolved variables
+	result, err := envsubst.SubstituteEnvs("$${APP_UNSET} $$APP_UNSET $APP_VALUE")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := "${APP_UNSET} $APP_UNSET $APP_UNSET"
+	if result != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, result)
+	}
+	if strings.Contains(logBuffer.String(), "APP_UNSET") {
+		t.Errorf("Unexpected debug log for escaped placeholder: %s", logBuffer.String())
+	}
+}
+
+func TestSubstituteEnvs_NestedExpansion(t *testing.T) {
+	envs := map[string]string{
+		"APP_ENV":        "PROD",
+		"APP_HOST_PROD":  "db.prod",
+		"APP_HOST_DEV":   "db.dev",
+		"APP_HOST_VAR":   "APP_HOST_PROD",
+		"APP_HOST_REF":   "APP_HOST_${APP_ENV}",
+		"APP_REF_REF":    "${APP_HOST_VAR}",
+		"OTHER_HOST_VAR": "APP_HOST_DEV",
+		"APP_SECRET_VAR": "OTHER_SECRET",
+		"OTHER_SECRET":   "secret",
+	}
+	for k, v := range envs {
+		os.Setenv(k, v)
+	}
+	defer func() {
+		for k := range envs {
+			os.Unsetenv(k)
+		}
+	}()
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "Nested", input: "${APP_HOST_${APP_ENV}}", expected: "db.prod"},
+		{name: "Nested with operator", input: "${APP_HOST_${APP_ENV}#db.}", expected: "prod"},
+		{name: "Nested inner operator", input: "${APP_HOST_${APP_UNSET:-DEV}}", expected: "db.dev"},
+		{name: "Nested outer operator", input: "${APP_PORT_${APP_ENV}:-5432}", expected: "5432"},
+		{name: "Nested with filter", input: "${APP_HOST_${APP_ENV} | upper}", expected: "DB.PROD"},
+		{name: "Indirect", input: "${!APP_HOST_VAR}", expected: "db.prod"},
+		{name: "Indirect with nested value", input: "${!APP_HOST_REF}", expected: "db.prod"},
+		{name: "Indirect with placeholder value", input: "${!APP_REF_REF}", expected: "db.prod"},
+		{name: "Indirect nested", input: "${!APP_${APP_ENV | lower | replace prod HOST_VAR}}", expected: "db.prod"},
+		{name: "Inner not allowed", input: "${APP_HOST_${OTHER_ENV}}", expected: "${APP_HOST_${OTHER_ENV}}"},
+		{name: "Reference not allowed", input: "${!OTHER_HOST_VAR}", expected: "${!OTHER_HOST_VAR}"},
+		{name: "Final name not allowed", input: "${!APP_SECRET_VAR}", expected: "${!APP_SECRET_VAR}"},
+		{name: "Escaped", input: "$${APP_HOST_${APP_ENV}} $${!APP_HOST_VAR}", expected: "${APP_HOST_${APP_ENV}} ${!APP_HOST_VAR}"},
+		{name: "Bash array keys", input: "${!array[@]}", expected: "${!array[@]}"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			envsubst := NewEnvsubst([]string{}, []string{"APP_"}, true)
+			result, err := envsubst.SubstituteEnvs(test.input)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result != test.expected {
+				t.Errorf("Expected '%s', got '%s'", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestSubstituteEnvs_NestedExpansion_Errors(t *testing.T) {
+	envs := map[string]string{
+		"APP_ENV":    "prod-eu",
+		"APP_LOOP_A": "${!APP_LOOP_B}",
+		"APP_LOOP_B": "${!APP_LOOP_A}",
+		"APP_SELF":   "${!APP_SELF}",
+		"APP_BAD":    "APP_${",
+	}
+	for k, v := range envs {
+		os.Setenv(k, v)
+	}
+	defer func() {
+		for k := range envs {
+			os.Unsetenv(k)
+		}
+	}()
+
+	tests := []struct {
+		name          string
+		input         string
+		expectedError string
+	}{
+		{name: "Invalid name", input: "host: ${APP_HOST_${APP_ENV}}", expectedError: `1:7: invalid variable name "APP_HOST_prod-eu" in placeholder ${APP_HOST_${APP_ENV}}`},
+		{name: "Invalid reference", input: "${!APP_BAD}", expectedError: `1:1: ${!APP_BAD}: invalid variable name "APP_${"`},
+		{name: "Cycle", input: "${!APP_LOOP_A}", expectedError: "1:1: cyclic indirect reference: APP_LOOP_A -> APP_LOOP_B -> APP_LOOP_A"},
+		{name: "Self reference", input: "${!APP_SELF}", expectedError: "1:1: cyclic indirect reference: APP_SELF -> APP_SELF"},
+		{name: "Unresolved final name", input: "${APP_HOST_${APP_UNSET:-DEV}}", expectedError: "undefined variables: [APP_HOST_DEV]\n  1:1: ${APP_HOST_${APP_UNSET:-DEV}}"},
+		{name: "Unresolved inner name", input: "${APP_HOST_${APP_UNSET}}", expectedError: "undefined variables: [APP_UNSET]\n  1:12: ${APP_UNSET}"},
+		{name: "Unresolved reference", input: "${!APP_UNSET}", expectedError: "undefined variables: [APP_UNSET]\n  1:1: ${!APP_UNSET}"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			envsubst := NewEnvsubst([]string{}, []string{"APP_"}, true)
+			_, err := envsubst.SubstituteEnvs(test.input)
+			if err == nil {
+				t.Fatal("Expected an error, but got none")
+			}
+			if err.Error() != test.expectedError {
+				t.Errorf("Expected error '%s', got '%s'", test.expectedError, err.Error())
+			}
+		})
+	}
+}
+
+func TestSubstituteEnvs_NestedExpansion_DepthLimit(t *testing.T) {
+	input := "${APP_X}"
+	for i := 0; i < maxExpansionDepth+1; i++ {
+		input = "${APP_X" + input + "}"
+	}
+
+	envsubst := NewEnvsubst([]string{}, []string{"APP_"}, false)
+	_, err := envsubst.SubstituteEnvs(input)
+	if err == nil || !strings.Contains(err.Error(), "is nested too deeply") {
+		t.Errorf("Expected a depth limit error, got %v", err)
+	}
+}
+
+func TestSubstituteEnvs_Delimiters(t *testing.T) {
+	os.Setenv("APP_ENV", "PROD")
+	os.Setenv("APP_HOST_PROD", "db.prod")
+	os.Setenv("APP_HOST_REF", "APP_HOST_{{ APP_ENV }}")
+	defer os.Unsetenv("APP_ENV")
+	defer os.Unsetenv("APP_HOST_PROD")
+	defer os.Unsetenv("APP_HOST_REF")
+
+	tests := []struct {
+		name     string
+		delims   string
+		input    string
+		expected string
+	}{
+		{
+			name:     "Double braces",
+			delims:   "{{,}}",
+			input:    `expr: rate(x{env="{{ APP_ENV | lower }}"}[5m]) > ${THRESHOLD} # {{ $labels.pod }}`,
+			expected: `expr: rate(x{env="prod"}[5m]) > ${THRESHOLD} # {{ $labels.pod }}`,
+		},
+		{
+			name:     "Double braces, operators and nesting",
+			delims:   "{{,}}",
+			input:    "host: {{ APP_HOST_{{ APP_ENV }} }}, port: {{ APP_PORT:-5432 }}, ref: {{ !APP_HOST_REF }}",
+			expected: "host: db.prod, port: 5432, ref: db.prod",
+		},
+		{
+			name:     "Double braces, context escaping",
+			delims:   "{{,}}",
+			input:    "key: {{ APP_MISSING:-a: b }}",
+			expected: `key: "a: b"`,
+		},
+		{
+			name:     "At signs",
+			delims:   "@,@",
+			input:    "image: registry/@APP_ENV@ # maintainer@example.com, @@APP_ENV@",
+			expected: "image: registry/PROD # maintainer@example.com, @APP_ENV@",
+		},
+		{
+			name:     "Percent braces",
+			delims:   "%{,}",
+			input:    "run: echo $HOME %{APP_ENV,,} 100%",
+			expected: "run: echo $HOME prod 100%",
+		},
+		{
+			name:     "Directive",
+			delims:   "${,}",
+			input:    "# kubectl-envsubst: delimiters={{,}}\nenv: {{ APP_ENV }} ${APP_ENV}",
+			expected: "# kubectl-envsubst: delimiters={{,}}\nenv: PROD ${APP_ENV}",
+		},
+		{
+			name:     "Directive overrides the run",
+			delims:   "@,@",
+			input:    "# kubectl-envsubst: delimiters=${,}\nenv: v@APP_ENV@ ${APP_ENV}",
+			expected: "# kubectl-envsubst: delimiters=${,}\nenv: v@APP_ENV@ PROD",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			envsubst := NewEnvsubst([]string{}, []string{"APP_"}, true)
+			if err := envsubst.SetDelimiters(test.delims); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			result, err := envsubst.SubstituteEnvs(test.input)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result != test.expected {
+				t.Errorf("Expected '%s', got '%s'", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestSubstituteEnvs_Delimiters_StrictMode(t *testing.T) {
+	envsubst := NewEnvsubst([]string{}, []string{"APP_"}, true)
+	envsubst.SetFilename("rules.yaml")
+	if err := envsubst.SetDelimiters("{{,}}"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	_, err := envsubst.SubstituteEnvs("env: {{ APP_UNSET }}\nother: ${APP_UNSET}")
+	expected := "undefined variables: [APP_UNSET]\n  rules.yaml:1:6: {{ APP_UNSET }}"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected error '%s', got '%v'", expected, err)
+	}
+}
+
+func TestSubstituteEnvs_Delimiters_InvalidDirective(t *testing.T) {
+	envsubst := NewEnvsubst([]string{}, []string{"APP_"}, true)
+	envsubst.SetFilename("cm.yaml")
+
+	_, err := envsubst.SubstituteEnvs("# kubectl-envsubst: delimiters={{\nkind: ConfigMap")
+	if err == nil || !strings.HasPrefix(err.Error(), "cm.yaml:1:1: invalid delimiters") {
+		t.Errorf("Expected an invalid delimiters error, got '%v'", err)
+	}
+}
+
+func TestSubstituteEnvs_BracesOnly(t *testing.T) {
+	os.Setenv("APP_NAME", "my-app")
+	defer os.Unsetenv("APP_NAME")
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "Braced", input: "name: ${APP_NAME}", expected: "name: my-app"},
+		{name: "Unbraced", input: "name: $APP_NAME", expected: "name: $APP_NAME"},
+		{name: "Unbraced unresolved", input: "run: echo $APP_UNSET $HOME", expected: "run: echo $APP_UNSET $HOME"},
+		{name: "Unbraced in operator", input: "name: ${APP_UNSET:-$APP_NAME}", expected: "name: $APP_NAME"},
+		{name: "Escaped", input: "run: echo $${APP_NAME} $$APP_NAME", expected: "run: echo ${APP_NAME} $$APP_NAME"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			envsubst := NewEnvsubst([]string{}, []string{"APP_"}, true)
+			envsubst.SetBracesOnly(true)
+			result, err := envsubst.SubstituteEnvs(test.input)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result != test.expected {
+				t.Errorf("Expected '%s', got '%s'", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestSubstituteEnvs_BracesOnly_StrictMode(t *testing.T) {
+	envsubst := NewEnvsubst([]string{}, []string{"APP_"}, true)
+	envsubst.SetBracesOnly(true)
+	envsubst.SetVerbose(true)
+
+	logBuffer := strings.Builder{}
+	log.SetOutput(&logBuffer)
+	defer log.SetOutput(os.Stderr)
+
+	// unbraced placeholders are neither errors in strict mode, nor reported in verbose mode
+	if _, err := envsubst.SubstituteEnvs("run: echo $APP_UNSET $HOME"); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if logBuffer.Len() != 0 {
+		t.Errorf("Unexpected debug log: %s", logBuffer.String())
+	}
+
+	_, err := envsubst.SubstituteEnvs("run: echo $APP_UNSET ${APP_UNSET}")
+	expected := "undefined variables: [APP_UNSET]\n  1:22: ${APP_UNSET}"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected error '%s', got '%v'", expected, err)
+	}
+}
+
+func TestSubstituteEnvs_FileVars(t *testing.T) {
+	os.Setenv("APP_OVERRIDDEN", "from-env")
+	defer os.Unsetenv("APP_OVERRIDDEN")
+
+	envsubst := NewEnvsubst([]string{"DB_HOST"}, []string{"APP_"}, true, EnvSource{}, MapSource{
+		"APP_NAME":       "from-file",
+		"APP_OVERRIDDEN": "from-file",
+		"DB_HOST":        "db.local",
+		"OTHER":          "not-allowed",
+	})
+
+	// the process environment takes precedence over env files, filters apply to both
+	result, err := envsubst.SubstituteEnvs("$APP_NAME $APP_OVERRIDDEN $DB_HOST $OTHER")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := "from-file from-env db.local $OTHER"
+	if result != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, result)
+	}
+}
+
+func TestSubstituteEnvs_ValuesPaths(t *testing.T) {
+	values := MapSource{
+		"db.primary.host": "db.local",
+		"db.primary.port": "5432",
+		"images[0].tag":   "1.2.3",
+		"APP_ENV":         "primary",
+		"other.value":     "not-allowed",
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "Dotted path", input: "host: ${db.primary.host}:${db.primary.port}", expected: "host: db.local:5432"},
+		{name: "Indexed path", input: "tag: ${images[0].tag}", expected: "tag: 1.2.3"},
+		{name: "Nested name in path", input: "host: ${db.${APP_ENV}.host}", expected: "host: db.local"},
+		{name: "Default for a missing path", input: "tag: ${images[1].tag:-latest}", expected: "tag: latest"},
+		{name: "Path with a filter", input: "host: ${db.primary.host | upper}", expected: "host: DB.LOCAL"},
+		{name: "Path not in filter", input: "value: ${other.value}", expected: "value: ${other.value}"},
+		{name: "Plain placeholder is not a path", input: "host: $db.primary.host", expected: "host: $db.primary.host"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// a path is allowed with its root
+			envsubst := NewEnvsubst([]string{"db", "images"}, []string{"APP_"}, false, values)
+			result, err := envsubst.SubstituteEnvs(test.input)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result != test.expected {
+				t.Errorf("Expected '%s', got '%s'", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestSubstituteEnvs_ValuesPaths_StrictMode(t *testing.T) {
+	envsubst := NewEnvsubst([]string{"db"}, []string{}, true, MapSource{"db.primary.host": "db.local"})
+	envsubst.SetFilename("deployment.yaml")
+
+	_, err := envsubst.SubstituteEnvs("host: ${db.primary.host}\nport: ${db.primary.port}")
+	expected := "undefined variables: [db.primary.port]\n  deployment.yaml:2:7: ${db.primary.port}"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected error '%s', got '%v'", expected, err)
+	}
+}
+
+func TestSubstituteEnvs_FileReferences(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "certs", "tls.crt"), "line1\nline2\n")
+	writeTestFile(t, filepath.Join(dir, "certs", "name.txt"), "my-app")
+	resolver, err := NewFileResolver(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		input         string
+		expected      string
+		expectedError string
+	}{
+		{name: "Plain scalar", input: "name: ${file:certs/name.txt}", expected: "name: my-app"},
+		{name: "Encoding filter", input: "tls.crt: ${file:certs/tls.crt | b64enc}", expected: "tls.crt: bGluZTEKbGluZTIK"},
+		{name: "Block scalar", input: "data:\n  tls.crt: |\n    ${file:certs/tls.crt}", expected: "data:\n  tls.crt: |\n    line1\n    line2\n"},
+		{name: "Default of a variable", input: "name: ${APP_NAME:-${file:certs/name.txt}}", expected: "name: my-app"},
+		{name: "Missing file", input: "\nname: ${file:certs/missing.txt}", expectedError: "deployment.yaml:2:7: ${file:certs/missing.txt}: file certs/missing.txt does not exist"},
+		{name: "Invalid filter", input: "name: ${file:certs/name.txt | unknown}", expectedError: "deployment.yaml:1:7: file:certs/name.txt: unknown filter: unknown"},
+		{name: "Not allowed path", input: "name: ${file:name.txt}", expected: "name: ${file:name.txt}"},
+		{name: "Escaped not allowed path", input: "name: $${file:name.txt}", expected: "name: $${file:name.txt}"},
+		{name: "Denied path", input: "key: ${file:certs/tls.key}", expected: "key: ${file:certs/tls.key}"},
+		{name: "Path escaping an allowed prefix", input: "name: ${file:certs/../name.txt}", expected: "name: ${file:certs/../name.txt}"},
+		{name: "Path escaping a denied prefix", input: "key: ${file:certs/tmp/../tls.key}", expected: "key: ${file:certs/tmp/../tls.key}"},
+		{name: "Cleaned path", input: "name: ${file:certs/./tmp/../name.txt}", expected: "name: my-app"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			envsubst := NewEnvsubst([]string{}, []string{"APP_", "file:certs/"}, true, MapSource{})
+			envsubst.SetFilename(filepath.Join(dir, "deployment.yaml"))
+			envsubst.SetResolver("file", resolver)
+			envsubst.SetDenied(nil, []string{"file:certs/tls.key"})
+
+			result, err := envsubst.SubstituteEnvs(test.input)
+			if test.expectedError != "" {
+				expectedError := strings.ReplaceAll(test.expectedError, "deployment.yaml", filepath.Join(dir, "deployment.yaml"))
+				if err == nil || err.Error() != expectedError {
+					t.Errorf("Expected error '%s', got '%v'", expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result != test.expected {
+				t.Errorf("Expected '%s', got '%s'", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestSubstituteEnvs_FileReferences_Disabled(t *testing.T) {
+	// references remain unchanged without a resolver, and are not reported in strict mode
+	envsubst := NewEnvsubst([]string{}, []string{"APP_"}, true, MapSource{})
+	input := "name: ${file:certs/name.txt}\ncert: ${file:certs/tls.crt | unknown}"
+	result, err := envsubst.SubstituteEnvs(input)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result != input {
+		t.Errorf("Expected '%s', got '%s'", input, result)
+	}
+}
+
+func TestSubstituteEnvs_HashReferences(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "configmap.yaml"), "data")
+	files, err := NewFileResolver(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the hash of a file and the hash of the input set are resolved independently
+	envsubst := NewEnvsubst([]string{}, []string{"APP_"}, true, MapSource{})
+	envsubst.SetFilename(filepath.Join(dir, "deployment.yaml"))
+	envsubst.SetResolver("ENVSUBST_HASH", NewHashResolver(files))
+	envsubst.AddBuiltins(map[string]string{"ENVSUBST_HASH": "abc"})
+
+	result, err := envsubst.SubstituteEnvs("checksum/config: ${ENVSUBST_HASH:configmap.yaml}\nchecksum/all: ${ENVSUBST_HASH}")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := "checksum/config: 3a6eb0790f39ac87c94f3856b2dd2c5d110e6811602261a9a923d3bb23adc8b7\nchecksum/all: abc"
+	if result != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, result)
+	}
+
+	// hashes of files are built-in, regardless of the allowed lists, while the deny lists apply
+	envsubst.SetDenied(nil, []string{"ENVSUBST_HASH:secrets/"})
+	input := "checksum/secret: ${ENVSUBST_HASH:secrets/app.yaml}"
+	if result, err = envsubst.SubstituteEnvs(input); err != nil || result != input {
+		t.Errorf("Expected '%s', got '%s', %v", input, result, err)
+	}
+}
+
+func TestSubstituteEnvs_Builtins(t *testing.T) {
+	os.Setenv("ENVSUBST_GIT_BRANCH", "from-env")
+	defer os.Unsetenv("ENVSUBST_GIT_BRANCH")
+
+	envsubst := NewEnvsubst([]string{}, []string{"APP_"}, true)
+	envsubst.AddBuiltins(map[string]string{
+		"ENVSUBST_GIT_SHORT_SHA": "1a2b3c4",
+		"ENVSUBST_GIT_BRANCH":    "main",
+		"ENVSUBST_GIT_TAG":       "",
+	})
+
+	// built-ins are allowed regardless of filter lists, the environment takes precedence over them
+	input := "image: app:${ENVSUBST_GIT_SHORT_SHA}\nbranch: $ENVSUBST_GIT_BRANCH\ntag: ${ENVSUBST_GIT_TAG:-untagged}\nother: $ENVSUBST_OTHER"
+	result, err := envsubst.SubstituteEnvs(input)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := "image: app:1a2b3c4\nbranch: from-env\ntag: untagged\nother: $ENVSUBST_OTHER"
+	if result != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, result)
+	}
+}
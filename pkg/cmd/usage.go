@@ -19,6 +19,39 @@ Examples:
   # example usage with other kubectl flags
   kubectl envsubst apply -f manifests/ --dry-run=client -oyaml --envsubst-allowed-prefixes=APP_
 
+  # encrypt an env file, and use it while applying manifests
+  kubectl envsubst encrypt --envsubst-decrypt-key=prod.key envs/prod.env > envs/prod.env.enc
+  kubectl envsubst apply -f manifests/ --envsubst-env-file=envs/prod.env.enc --envsubst-decrypt-key=prod.key
+
+Placeholders:
+  $VAR, ${VAR}          value of the variable
+  ${VAR:-default}       default, if the variable is unset or empty (${VAR-default}: if unset)
+  ${VAR:=default}       same as above, the default is also assigned to the variable
+  ${VAR:+alt}           alternate value, if the variable is set and non-empty (${VAR+alt}: if set)
+  ${VAR:?message}       error with message, if the variable is unset or empty (${VAR?message}: if unset)
+  ${VAR^^}, ${VAR,,}    uppercase or lowercase (${VAR^}, ${VAR,}: the first character)
+  ${VAR:offset:length}  substring (${VAR: -3}: the last three characters)
+  ${VAR#prefix}         remove the shortest matching prefix (${VAR##prefix}: the longest)
+  ${VAR%suffix}         remove the shortest matching suffix (${VAR%%suffix}: the longest)
+  ${VAR/old/new}        replace the first match (${VAR//old/new}: all matches)
+  ${VAR_${INNER}}       nested placeholder in a name of a variable
+  ${!VAR}               indirect reference, the value of VAR is a name of a variable
+  ${db.host}, ${a[0].b} path of a value, loaded with --envsubst-values
+  ${file:certs/tls.crt} content of a file, relative to the manifest, enabled with --envsubst-file-root
+  ${exec:version}       output of a command, declared in the exec section of the config file
+  ${vault:secret/data/app#password}
+                        key of a secret of Vault KV v2, enabled with --envsubst-vault, with VAULT_ADDR and VAULT_TOKEN
+                        names of references are filtered like variables: --envsubst-allowed-prefixes=file:certs/
+  ${VAR | b64enc}       pipeline of filters: b64enc, b64dec, quote, squote, upper, lower, trim,
+                        trunc N, replace OLD NEW, indent N, nindent N, sha256, urlquery, dns1123, raw,
+                        autoindent (continuation lines are indented to the column of the placeholder)
+                        values are escaped for the YAML scalar they are placed in, unless raw is used,
+                        multi-line values in block scalars are indented to the column of the placeholder
+  $$VAR, $${VAR}        escaped placeholder of an allowed variable, emitted as a literal $VAR, ${VAR},
+                        escaped placeholders of other variables remain unchanged
+  {{ VAR }}, @VAR@      custom delimiters, set with --envsubst-delimiters, or in a leading comment of a file:
+                        # kubectl-envsubst: delimiters={{,}}
+
 Flags:
   --envsubst-allowed-vars
       Accepts a comma-separated list of variable names allowed for substitution. 
@@ -27,4 +60,75 @@ Flags:
   --envsubst-allowed-prefixes
       Accepts a comma-separated list of prefixes. 
       Only variables with names starting with one of these prefixes will be substituted; others will be ignored.
+
+  --envsubst-allowed-patterns
+      Accepts a comma-separated list of patterns of allowed variables: globs (*_IMAGE), regular expressions
+      in slashes (/^CI_(JOB|PIPELINE)_ID$/), and negated patterns (!APP_DEBUG), that exclude names from other patterns.
+      Commas inside of regular expressions do not separate patterns: /^APP_[0-9]{2,3}$/.
+
+  --envsubst-denied-vars, --envsubst-denied-prefixes
+      Accept comma-separated lists of names and prefixes of variables, that are never substituted.
+      Deny lists take precedence over allowed vars, prefixes, patterns and built-in variables.
+
+  --envsubst-denied-policy
+      Handling of placeholders of denied variables: 'ignore' (the default) leaves them unchanged,
+      'error' fails with a list of denied variables and their locations.
+
+  --envsubst-strict
+      Chooses unresolved placeholders, that are errors: 'filtered' (the default) fails on undefined variables
+      of the allowed lists, 'all' fails on any placeholder that remains in a document, 'off' never fails.
+      All the failures of a document are reported with a single error, that lists variables and locations.
+
+  --envsubst-delimiters
+      Accepts opening and closing delimiters of placeholders, separated by a comma: {{,}}, @,@, %{,}.
+      The default is ${,}, that also allows $VAR placeholders.
+
+  --envsubst-braces-only
+      Substitutes braced placeholders only: ${VAR}, unbraced $VAR references remain unchanged.
+
+  --envsubst-env-file
+      Loads variables from a dotenv file, may be repeated, later files override earlier ones.
+      The process environment takes precedence over env files.
+      A .envsubst.env file in the directory of a manifest, or its ancestors, is loaded automatically, the nearest
+      one wins. Env files take precedence over these overlays.
+
+  --envsubst-decrypt-key
+      Key file of encrypted env files (AES-256-GCM, 32 bytes encoded in base64), they are decrypted in memory.
+      The same key is used by 'encrypt FILE' and 'decrypt FILE' commands, that write the result to stdout.
+
+  --envsubst-values
+      Loads a tree of values from a YAML or JSON file, may be repeated, later files override earlier ones.
+      Values are addressed with paths: ${db.primary.host}, ${images[0].tag}, env files take precedence.
+
+  --envsubst-values-from-manifest
+      Loads keys of ConfigMaps and Secrets from a manifest on disk, may be repeated, later manifests override
+      earlier ones. Values of Secrets are decoded from base64.
+
+  --envsubst-git
+      Enables built-in variables of the git repository of a manifest, read from its .git directory:
+      ENVSUBST_GIT_SHA, ENVSUBST_GIT_SHORT_SHA, ENVSUBST_GIT_BRANCH, ENVSUBST_GIT_TAG, ENVSUBST_GIT_DIRTY.
+
+  --envsubst-seed
+      Makes computed built-in variables reproducible: ENVSUBST_RANDOM_SUFFIX, ENVSUBST_UUID, the timestamp
+      is the Unix epoch. SOURCE_DATE_EPOCH sets ENVSUBST_TIMESTAMP, with or without a seed.
+
+  --envsubst-explain
+      Prints to stderr, for each placeholder of each file: the variable, the rule that allowed it (var or prefix),
+      the source of its value, and whether it was left untouched. Values are masked.
+
+  --envsubst-explain-values
+      Shows values in the output of --envsubst-explain, values of variables with sensitive names (PASSWORD,
+      TOKEN, KEY, ...) and of references remain masked.
+
+  --envsubst-config
+      Loads the plugin config file (YAML), that declares the allowlist of commands of ${exec:name} placeholders.
+      Commands are executed once per run, with a timeout (10s by default).
+
+  --envsubst-file-root
+      Enables file references: ${file:certs/tls.crt}, paths are relative to the manifest (or its URL),
+      and must be inside of the root directory.
+
+  --envsubst-vault
+      Enables references to secrets of Vault KV v2: ${vault:secret/data/app#password}, the server and the token
+      are set by VAULT_ADDR, VAULT_TOKEN (or ~/.vault-token) and VAULT_NAMESPACE.
 `)
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Values files (YAML or JSON) hold a tree of values, that are addressed with paths in placeholders:
+//
+//	db:
+//	  primary:
+//	    host: db.local      # ${db.primary.host}
+//	images:
+//	  - tag: 1.2.3          # ${images[0].tag}
+//
+// Only scalars are variables, keys that are not valid variable names cannot be addressed, and are skipped.
+
+// LoadValuesFiles reads values files into variables named by paths,
+// a value of a later file overrides the one of an earlier file
+func LoadValuesFiles(filenames []string) (map[string]string, error) {
+	vars := make(map[string]string)
+	for _, filename := range filenames {
+		filename = strings.TrimSpace(filename)
+		if filename == "" {
+			continue
+		}
+		content, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		if err := parseValues(content, vars); err != nil {
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+	}
+	return vars, nil
+}
+
+// parseValues parses a YAML or JSON document, scalars are added to vars
+func parseValues(content []byte, vars map[string]string) error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return err
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	root := resolveAlias(doc.Content[0])
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("values must be a mapping")
+	}
+	flattenValues(root, "", vars)
+	return nil
+}
+
+// flattenValues adds scalars of a node to vars, the path is the name of the node
+func flattenValues(node *yaml.Node, path string, vars map[string]string) {
+	node = resolveAlias(node)
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if node.Tag == "!!null" {
+			vars[path] = ""
+			return
+		}
+		vars[path] = node.Value
+
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			if !isName(key) {
+				continue
+			}
+			if path != "" {
+				key = path + "." + key
+			}
+			flattenValues(node.Content[i+1], key, vars)
+		}
+
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			flattenValues(item, path+"["+strconv.Itoa(i)+"]", vars)
+		}
+	}
+}
+
+// resolveAlias returns the node an alias refers to: *anchor
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	return node
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseValues(t *testing.T) {
+	content := `
+db:
+  primary: &primary
+    host: db.local
+    port: 5432
+  replica: *primary
+  password: null
+images:
+  - name: app
+    tag: "1.2.3"
+  - name: sidecar
+    tag: latest
+enabled: true
+"not a name": skipped
+`
+	vars := map[string]string{}
+	if err := parseValues([]byte(content), vars); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := map[string]string{
+		"db.primary.host": "db.local",
+		"db.primary.port": "5432",
+		"db.replica.host": "db.local",
+		"db.replica.port": "5432",
+		"db.password":     "",
+		"images[0].name":  "app",
+		"images[0].tag":   "1.2.3",
+		"images[1].name":  "sidecar",
+		"images[1].tag":   "latest",
+		"enabled":         "true",
+	}
+	if !reflect.DeepEqual(vars, expected) {
+		t.Errorf("Expected %v, got %v", expected, vars)
+	}
+}
+
+func TestParseValues_JSON(t *testing.T) {
+	vars := map[string]string{}
+	if err := parseValues([]byte(`{"app": {"name": "my-app", "ports": [80, 443]}}`), vars); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := map[string]string{"app.name": "my-app", "app.ports[0]": "80", "app.ports[1]": "443"}
+	if !reflect.DeepEqual(vars, expected) {
+		t.Errorf("Expected %v, got %v", expected, vars)
+	}
+}
+
+func TestParseValues_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "Not a mapping", content: "- a\n- b\n"},
+		{name: "Scalar", content: "value"},
+		{name: "Invalid YAML", content: "a: [b"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := parseValues([]byte(test.content), map[string]string{}); err == nil {
+				t.Error("Expected an error, but got none")
+			}
+		})
+	}
+}
+
+func TestLoadValuesFiles(t *testing.T) {
+	dir := t.TempDir()
+	common := filepath.Join(dir, "values.yaml")
+	prod := filepath.Join(dir, "prod.yaml")
+	invalid := filepath.Join(dir, "invalid.yaml")
+	if err := os.WriteFile(common, []byte("app:\n  env: dev\n  replicas: 1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(prod, []byte("app:\n  env: prod\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(invalid, []byte("- a\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	vars, err := LoadValuesFiles([]string{common, "", prod})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// later files override earlier ones
+	expected := map[string]string{"app.env": "prod", "app.replicas": "1"}
+	if !reflect.DeepEqual(vars, expected) {
+		t.Errorf("Expected %v, got %v", expected, vars)
+	}
+
+	if _, err := LoadValuesFiles([]string{filepath.Join(dir, "missing.yaml")}); err == nil {
+		t.Error("Expected an error for a missing file, but got none")
+	}
+	if _, err := LoadValuesFiles([]string{invalid}); err == nil || !strings.HasPrefix(err.Error(), invalid+": ") {
+		t.Errorf("Expected an error naming the file, got %v", err)
+	}
+}
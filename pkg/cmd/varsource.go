@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"os"
+	"sort"
+)
+
+// VarSource provides values of variables for substitution
+type VarSource interface {
+	// Lookup returns the value of a variable, and whether it is set
+	Lookup(name string) (string, bool)
+	// Names returns names of all variables of the source
+	Names() []string
+}
+
+// EnvSource provides variables of the process environment
+type EnvSource struct{}
+
+func (EnvSource) Lookup(name string) (string, bool) {
+	return os.LookupEnv(name)
+}
+
+func (EnvSource) Names() []string {
+	env := preprocessEnv()
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// MapSource provides variables from a map
+type MapSource map[string]string
+
+func (m MapSource) Lookup(name string) (string, bool) {
+	value, ok := m[name]
+	return value, ok
+}
+
+func (m MapSource) Names() []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ChainSource provides variables from a list of sources, the first source that has a variable wins
+type ChainSource []VarSource
+
+func (c ChainSource) Lookup(name string) (string, bool) {
+	for _, source := range c {
+		if value, ok := source.Lookup(name); ok {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+func (c ChainSource) Names() []string {
+	names := []string{}
+	seen := make(map[string]bool)
+	for _, source := range c {
+		for _, name := range source.Names() {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NamedSource names a source of variables, the name is reported in explanations: env files, values files
+type NamedSource struct {
+	Name string
+	VarSource
+}
+
+// sourceName returns the name of the source, that provides a variable
+func sourceName(source VarSource, name string) string {
+	switch s := source.(type) {
+	case NamedSource:
+		return s.Name
+	case ChainSource:
+		for _, inner := range s {
+			if _, ok := inner.Lookup(name); ok {
+				return sourceName(inner, name)
+			}
+		}
+	case EnvSource:
+		return "environment"
+	}
+	return "source"
+}
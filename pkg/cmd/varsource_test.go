@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestEnvSource(t *testing.T) {
+	os.Setenv("VARSOURCE_TEST", "value")
+	defer os.Unsetenv("VARSOURCE_TEST")
+
+	source := EnvSource{}
+	if value, ok := source.Lookup("VARSOURCE_TEST"); !ok || value != "value" {
+		t.Errorf("Expected 'value', got '%s' (%v)", value, ok)
+	}
+	if _, ok := source.Lookup("VARSOURCE_TEST_UNSET"); ok {
+		t.Error("Expected an unset variable")
+	}
+	if !varInSlice("VARSOURCE_TEST", source.Names()) {
+		t.Errorf("Expected VARSOURCE_TEST in %v", source.Names())
+	}
+}
+
+func TestMapSource(t *testing.T) {
+	source := MapSource{"B": "2", "A": "1", "EMPTY": ""}
+
+	if value, ok := source.Lookup("A"); !ok || value != "1" {
+		t.Errorf("Expected '1', got '%s' (%v)", value, ok)
+	}
+	if value, ok := source.Lookup("EMPTY"); !ok || value != "" {
+		t.Errorf("Expected a set empty variable, got '%s' (%v)", value, ok)
+	}
+	if _, ok := source.Lookup("C"); ok {
+		t.Error("Expected an unset variable")
+	}
+	if names := source.Names(); !reflect.DeepEqual(names, []string{"A", "B", "EMPTY"}) {
+		t.Errorf("Expected sorted names, got %v", names)
+	}
+}
+
+func TestChainSource(t *testing.T) {
+	source := ChainSource{
+		MapSource{"A": "first", "B": ""},
+		MapSource{"A": "second", "B": "second", "C": "second"},
+	}
+
+	tests := []struct {
+		name     string
+		expected string
+		ok       bool
+	}{
+		{name: "A", expected: "first", ok: true},
+		{name: "B", expected: "", ok: true},
+		{name: "C", expected: "second", ok: true},
+		{name: "D", expected: "", ok: false},
+	}
+	for _, test := range tests {
+		value, ok := source.Lookup(test.name)
+		if value != test.expected || ok != test.ok {
+			t.Errorf("For %s, expected '%s' (%v), got '%s' (%v)", test.name, test.expected, test.ok, value, ok)
+		}
+	}
+
+	if names := source.Names(); !reflect.DeepEqual(names, []string{"A", "B", "C"}) {
+		t.Errorf("Expected unique sorted names, got %v", names)
+	}
+	if names := (ChainSource{}).Names(); len(names) != 0 {
+		t.Errorf("Expected no names, got %v", names)
+	}
+}
+
+func TestNewEnvsubst_Sources(t *testing.T) {
+	os.Setenv("APP_FROM_ENV", "env")
+	defer os.Unsetenv("APP_FROM_ENV")
+
+	tests := []struct {
+		name     string
+		sources  []VarSource
+		expected string
+	}{
+		{
+			name:     "Process environment by default",
+			expected: "env ${APP_FROM_MAP}",
+		},
+		{
+			name:     "Single source",
+			sources:  []VarSource{MapSource{"APP_FROM_MAP": "map"}},
+			expected: "${APP_FROM_ENV} map",
+		},
+		{
+			name:     "Chained sources",
+			sources:  []VarSource{MapSource{"APP_FROM_MAP": "map"}, EnvSource{}, MapSource{"APP_FROM_ENV": "shadowed"}},
+			expected: "env map",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			envsubst := NewEnvsubst(nil, []string{"APP_"}, false, test.sources...)
+			result, err := envsubst.SubstituteEnvs("${APP_FROM_ENV} ${APP_FROM_MAP}")
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result != test.expected {
+				t.Errorf("Expected '%s', got '%s'", test.expected, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// vaultTimeout limits a request to the Vault server
+const vaultTimeout = 10 * time.Second
+
+// VaultResolver resolves references to keys of secrets of the Vault KV v2 engine: ${vault:secret/data/app#password}.
+// The path includes the data segment of the KV v2 API, each secret is read once, and is shared by all the references.
+type VaultResolver struct {
+	addr      string
+	token     string
+	namespace string
+	client    *http.Client
+
+	mu      sync.Mutex
+	secrets map[string]vaultSecret
+}
+
+type vaultSecret struct {
+	data map[string]any
+	err  error
+}
+
+// NewVaultResolver creates a resolver of secrets of the Vault server at addr
+func NewVaultResolver(addr, token, namespace string) *VaultResolver {
+	return &VaultResolver{
+		addr:      strings.TrimSuffix(addr, "/"),
+		token:     token,
+		namespace: namespace,
+		client:    &http.Client{Timeout: vaultTimeout},
+		secrets:   make(map[string]vaultSecret),
+	}
+}
+
+// NewVaultResolverFromEnv creates a resolver configured the same way as the vault CLI:
+// VAULT_ADDR, VAULT_TOKEN (or the ~/.vault-token file) and VAULT_NAMESPACE
+func NewVaultResolverFromEnv() *VaultResolver {
+	token := os.Getenv("VAULT_TOKEN")
+	if token == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			if content, err := os.ReadFile(filepath.Join(home, ".vault-token")); err == nil {
+				token = strings.TrimSpace(string(content))
+			}
+		}
+	}
+	return NewVaultResolver(os.Getenv("VAULT_ADDR"), token, os.Getenv("VAULT_NAMESPACE"))
+}
+
+func (r *VaultResolver) Resolve(ref, _ string) (string, error) {
+	path, key, ok := strings.Cut(ref, "#")
+	if !ok || path == "" || key == "" {
+		return "", fmt.Errorf("vault reference %s: expected a path and a key: secret/data/app#password", ref)
+	}
+	if err := checkVaultPath(path); err != nil {
+		return "", fmt.Errorf("vault reference %s: %w", ref, err)
+	}
+
+	r.mu.Lock()
+	secret, ok := r.secrets[path]
+	if !ok {
+		data, err := r.readSecret(path)
+		secret = vaultSecret{data: data, err: err}
+		r.secrets[path] = secret
+	}
+	r.mu.Unlock()
+
+	if secret.err != nil {
+		return "", secret.err
+	}
+	value, ok := secret.data[key]
+	if !ok {
+		return "", fmt.Errorf("vault secret %s has no key %s", path, key)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	// numbers, booleans and nested objects are rendered as JSON
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("vault secret %s: key %s: %w", path, key, err)
+	}
+	return string(encoded), nil
+}
+
+// checkVaultPath rejects paths of secrets, that are resolved to another path by the server:
+// a leading '/', empty, '.' and '..' segments
+func checkVaultPath(secretPath string) error {
+	for _, segment := range strings.Split(secretPath, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return fmt.Errorf("invalid path of a vault secret %s, expected segments separated by '/'", secretPath)
+		}
+	}
+	return nil
+}
+
+// readSecret reads the latest version of a secret
+func (r *VaultResolver) readSecret(path string) (map[string]any, error) {
+	if r.addr == "" {
+		return nil, fmt.Errorf("vault secret %s: VAULT_ADDR is not set", path)
+	}
+	if r.token == "" {
+		return nil, fmt.Errorf("vault secret %s: VAULT_TOKEN is not set", path)
+	}
+	endpoint, err := url.JoinPath(r.addr, "v1", path)
+	if err != nil {
+		return nil, fmt.Errorf("vault secret %s: invalid VAULT_ADDR: %w", path, err)
+	}
+
+	request, err := http.NewRequest(http.MethodGet, endpoint, http.NoBody)
+	if err != nil {
+		return nil, fmt.Errorf("vault secret %s: %w", path, err)
+	}
+	request.Header.Set("X-Vault-Token", r.token)
+	if r.namespace != "" {
+		request.Header.Set("X-Vault-Namespace", r.namespace)
+	}
+	response, err := r.client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("vault secret %s: %w", path, err)
+	}
+	defer response.Body.Close()
+
+	var body struct {
+		Data struct {
+			Data map[string]any `json:"data"`
+		} `json:"data"`
+		Errors []string `json:"errors"`
+	}
+	decodeErr := json.NewDecoder(response.Body).Decode(&body)
+
+	switch {
+	case response.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("vault secret %s does not exist", path)
+	case response.StatusCode == http.StatusForbidden:
+		return nil, fmt.Errorf("vault secret %s: permission denied", path)
+	case response.StatusCode != http.StatusOK:
+		if len(body.Errors) > 0 {
+			return nil, fmt.Errorf("vault secret %s: %s: %s", path, response.Status, strings.Join(body.Errors, "; "))
+		}
+		return nil, fmt.Errorf("vault secret %s: %s", path, response.Status)
+	case decodeErr != nil:
+		return nil, fmt.Errorf("vault secret %s: invalid response: %w", path, decodeErr)
+	case body.Data.Data == nil:
+		// a deleted version has no data, a path without the data segment is not a KV v2 read
+		return nil, fmt.Errorf("vault secret %s has no data, the path of a KV v2 secret is mount/data/path", path)
+	}
+	return body.Data.Data, nil
+}
@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// newTestVault starts a stand-in of the KV v2 API, it counts requests of each path
+func newTestVault(t *testing.T, requests map[string]*atomic.Int32) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if counter, ok := requests[r.URL.Path]; ok {
+			counter.Add(1)
+		}
+		if r.Header.Get("X-Vault-Token") != "test-token" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/app":
+			_, _ = w.Write([]byte(`{"data":{"data":{"password":"s3cr3t","port":5432,"tls":{"enabled":true}},"metadata":{"version":3}}}`))
+		case "/v1/secret/data/deleted":
+			_, _ = w.Write([]byte(`{"data":{"data":null,"metadata":{"version":2}}}`))
+		case "/v1/secret/data/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(`{"errors":["internal error"]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestVaultResolver(t *testing.T) {
+	server := newTestVault(t, nil)
+	resolver := NewVaultResolver(server.URL+"/", "test-token", "")
+
+	tests := []struct {
+		name          string
+		ref           string
+		expected      string
+		expectedError string
+	}{
+		{name: "String value", ref: "secret/data/app#password", expected: "s3cr3t"},
+		{name: "Number value", ref: "secret/data/app#port", expected: "5432"},
+		{name: "Object value", ref: "secret/data/app#tls", expected: `{"enabled":true}`},
+		{name: "Missing key", ref: "secret/data/app#user", expectedError: "vault secret secret/data/app has no key user"},
+		{name: "Missing secret", ref: "secret/data/missing#password", expectedError: "vault secret secret/data/missing does not exist"},
+		{name: "Deleted version", ref: "secret/data/deleted#password", expectedError: "vault secret secret/data/deleted has no data, the path of a KV v2 secret is mount/data/path"},
+		{name: "Server error", ref: "secret/data/broken#password", expectedError: "vault secret secret/data/broken: 500 Internal Server Error: internal error"},
+		{name: "Parent segment", ref: "secret/data/app/../admin#password", expectedError: "vault reference secret/data/app/../admin#password: invalid path of a vault secret secret/data/app/../admin, expected segments separated by '/'"},
+		{name: "Leading slash", ref: "/secret/data/app#password", expectedError: "vault reference /secret/data/app#password: invalid path of a vault secret /secret/data/app, expected segments separated by '/'"},
+		{name: "Missing key separator", ref: "secret/data/app", expectedError: "vault reference secret/data/app: expected a path and a key: secret/data/app#password"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := resolver.Resolve(test.ref, "deployment.yaml")
+			if test.expectedError != "" {
+				if err == nil || err.Error() != test.expectedError {
+					t.Errorf("Expected error '%s', got '%v'", test.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result != test.expected {
+				t.Errorf("Expected '%s', got '%s'", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestVaultResolver_Cache(t *testing.T) {
+	requests := map[string]*atomic.Int32{
+		"/v1/secret/data/app":     {},
+		"/v1/secret/data/missing": {},
+	}
+	server := newTestVault(t, requests)
+	resolver := NewVaultResolver(server.URL, "test-token", "")
+
+	// each secret is read once per run, errors are cached as well
+	for i := 0; i < 3; i++ {
+		if _, err := resolver.Resolve("secret/data/app#password", "a.yaml"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if _, err := resolver.Resolve("secret/data/app#port", "b.yaml"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if _, err := resolver.Resolve("secret/data/missing#password", "a.yaml"); err == nil {
+			t.Fatal("Expected an error for a missing secret, but got none")
+		}
+	}
+	for path, counter := range requests {
+		if counter.Load() != 1 {
+			t.Errorf("Expected 1 request of %s, got %d", path, counter.Load())
+		}
+	}
+}
+
+func TestVaultResolver_Config(t *testing.T) {
+	server := newTestVault(t, nil)
+
+	tests := []struct {
+		name          string
+		addr          string
+		token         string
+		expectedError string
+	}{
+		{name: "Missing address", addr: "", token: "test-token", expectedError: "vault secret secret/data/app: VAULT_ADDR is not set"},
+		{name: "Missing token", addr: server.URL, token: "", expectedError: "vault secret secret/data/app: VAULT_TOKEN is not set"},
+		{name: "Wrong token", addr: server.URL, token: "other", expectedError: "vault secret secret/data/app: permission denied"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewVaultResolver(test.addr, test.token, "").Resolve("secret/data/app#password", "deployment.yaml")
+			if err == nil || err.Error() != test.expectedError {
+				t.Errorf("Expected error '%s', got '%v'", test.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestSubstituteEnvs_VaultReferences(t *testing.T) {
+	server := newTestVault(t, nil)
+	envsubst := NewEnvsubst([]string{}, []string{"APP_", "vault:secret/data/"}, true, MapSource{})
+	envsubst.SetFilename("deployment.yaml")
+	envsubst.SetResolver("vault", NewVaultResolver(server.URL, "test-token", ""))
+
+	result, err := envsubst.SubstituteEnvs("password: ${vault:secret/data/app#password | b64enc}")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result != "password: czNjcjN0" {
+		t.Errorf("Expected 'password: czNjcjN0', got '%s'", result)
+	}
+
+	// errors name the path, and the location of the placeholder
+	_, err = envsubst.SubstituteEnvs("\npassword: ${vault:secret/data/missing#password}")
+	if err == nil || !strings.HasPrefix(err.Error(), "deployment.yaml:2:11: ") || !strings.HasSuffix(err.Error(), "vault secret secret/data/missing does not exist") {
+		t.Errorf("Expected an error of the missing secret, got '%v'", err)
+	}
+
+	// paths out of the allowed lists, and denied paths are not read
+	envsubst.SetDenied(nil, []string{"vault:secret/data/admin"})
+	input := "token: ${vault:kv/data/app#token}\npassword: ${vault:secret/data/admin#password}"
+	result, err = envsubst.SubstituteEnvs(input)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result != input {
+		t.Errorf("Expected '%s', got '%s'", input, result)
+	}
+
+	// '..' segments cannot escape the allowed prefix to the denied one, such paths fail instead of being read
+	_, err = envsubst.SubstituteEnvs("password: ${vault:secret/data/app/../admin#password}")
+	expectedError := "deployment.yaml:1:11: invalid path of a vault secret secret/data/app/../admin, expected segments separated by '/' in placeholder ${vault:secret/data/app/../admin#password}"
+	if err == nil || err.Error() != expectedError {
+		t.Errorf("Expected error '%s', got '%v'", expectedError, err)
+	}
+}